@@ -2,49 +2,245 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/compose"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/handlers"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/oauth"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/policy"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/routes"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/tlsutil"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/translate"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 )
 
 func main() {
 	cfg := config.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		os.Exit(runOpenAPI(cfg, os.Args[2:]))
+	}
+
 	logger := logging.NewLoggerV2("gateway")
 
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", logging.Fields{"error": err.Error()})
+	}
+
 	// TODO(TEAM-PLATFORM): Migrate to structured logging throughout
 	logging.Infof("Starting gateway on port %s", cfg.Port)
 
+	apiKeys, err := apikey.NewStore(cfg.APIKeyStorePath)
+	if err != nil {
+		logger.Fatal("Failed to load API key store", logging.Fields{"error": err.Error()})
+	}
+
+	oauthClients, err := oauth.LoadRegistry(cfg.OAuthClientsPath)
+	if err != nil {
+		logger.Fatal("Failed to load OAuth clients", logging.Fields{"error": err.Error()})
+	}
+
+	pol, err := policy.Load(cfg.PolicyPath)
+	if err != nil {
+		logger.Fatal("Failed to load authorization policy", logging.Fields{"error": err.Error()})
+	}
+
+	bypasses, err := middleware.LoadBypassRegistry(cfg.LegacyBypassPath)
+	if err != nil {
+		logger.Fatal("Failed to load legacy bypass registry", logging.Fields{"error": err.Error()})
+	}
+
+	mtlsIdentities, err := middleware.LoadMTLSIdentities(cfg.MTLSIdentitiesPath)
+	if err != nil {
+		logger.Fatal("Failed to load mTLS identities", logging.Fields{"error": err.Error()})
+	}
+
+	schemas, err := schema.LoadDir(cfg.SchemaDir)
+	if err != nil {
+		logger.Fatal("Failed to load request schemas", logging.Fields{"error": err.Error()})
+	}
+
+	composites, err := compose.Load(cfg.CompositesPath)
+	if err != nil {
+		logger.Fatal("Failed to load composite endpoints", logging.Fields{"error": err.Error()})
+	}
+
+	translations, err := translate.Load(cfg.V1TranslationsPath)
+	if err != nil {
+		logger.Fatal("Failed to load v1 translations", logging.Fields{"error": err.Error()})
+	}
+	for _, rule := range translations {
+		if _, ok := schemas.Get(rule.Schema); rule.Schema != "" && !ok {
+			logger.Fatal("Unknown schema in v1 translation", logging.Fields{"route": rule.Route, "schema": rule.Schema})
+		}
+	}
+
+	v1Usage, err := usage.Open(cfg.V1UsagePath)
+	if err != nil {
+		logger.Fatal("Failed to load v1 usage records", logging.Fields{"error": err.Error()})
+	}
+
 	proxyClient := proxy.NewClient(cfg)
-	authMiddleware := middleware.NewAuthMiddleware(cfg)
-	h := handlers.NewHandlers(proxyClient, cfg)
 
-	router := routes.Setup(h, authMiddleware, cfg)
+	var webhooks *webhookqueue.Queue
+	if cfg.WebhookQueueDir != "" {
+		webhooks, err = webhookqueue.Open(cfg.WebhookQueueDir, deliverWebhook(proxyClient), webhookqueue.Options{
+			MaxAttempts: cfg.WebhookMaxAttempts,
+		})
+		if err != nil {
+			logger.Fatal("Failed to open webhook queue", logging.Fields{"error": err.Error()})
+		}
+	}
+
+	authMiddleware := middleware.NewAuthMiddleware(cfg, apiKeys, pol)
+	responseValidation := middleware.NewResponseValidationMiddleware(schemas, cfg)
+	validation := middleware.NewValidationMiddleware(schemas, cfg)
+	h := handlers.NewHandlers(proxyClient, cfg, handlers.Deps{
+		APIKeys:      apiKeys,
+		OAuthClients: oauthClients,
+		Bypasses:     bypasses,
+		Auth:         authMiddleware,
+		Webhooks:     webhooks,
+		Responses:    responseValidation,
+		Composites:   composites,
+		Translations: translations,
+		Validation:   validation,
+		V1Usage:      v1Usage,
+	})
+
+	ownershipMiddleware := middleware.NewOwnershipMiddleware(authMiddleware, map[string]middleware.OwnerLookup{
+		"orders":   proxyClient.OrderOwner,
+		"payments": proxyClient.PaymentOwner,
+	}, time.Duration(cfg.OwnershipCacheTTL)*time.Second)
+
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(cfg)
+
+	routeMW := routes.Middleware{
+		Auth:        authMiddleware,
+		Ownership:   ownershipMiddleware,
+		Bypass:      bypasses,
+		MTLS:        mtlsIdentities,
+		Admin:       middleware.NewAdminAuth(cfg.AdminToken),
+		RateLimit:   rateLimitMiddleware,
+		Timeout:     middleware.NewTimeoutMiddleware(cfg),
+		Idempotency: middleware.NewIdempotencyMiddleware(time.Duration(cfg.IdempotencyTTL) * time.Second),
+		Webhook:     middleware.NewWebhookVerifier(cfg.WebhookSecrets, time.Duration(cfg.WebhookTolerance)*time.Second),
+		Validation:  validation,
+		Responses:   responseValidation,
+		Deprecation: middleware.NewDeprecationMiddleware(cfg),
+		Usage:       middleware.NewUsageMiddleware(v1Usage, cfg.V1UsageTrustedProxies),
+	}
+	router, err := routes.Setup(h, routeMW, cfg, schemas)
+	if err != nil {
+		logger.Fatal("Failed to set up routes", logging.Fields{"error": err.Error()})
+	}
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: routes.WriteTimeout(h, routeMW, cfg),
 		IdleTimeout:  60 * time.Second,
 	}
 
+	stopWatchers := make(chan struct{})
+
+	if webhooks != nil {
+		go webhooks.Run(stopWatchers)
+	}
+	go v1Usage.Run(stopWatchers, time.Duration(max(cfg.V1UsageFlushInterval, 1))*time.Second)
+
+	certPairs, err := tlsutil.CertPairs(cfg)
+	if err != nil {
+		logger.Fatal("Invalid TLS certificate configuration", logging.Fields{"error": err.Error()})
+	}
+	useTLS := len(certPairs) > 0
+	if cfg.TLSClientCAFile != "" && !useTLS {
+		logger.Fatal("TLS_CLIENT_CA_FILE requires a TLS certificate")
+	}
+	if useTLS {
+		reloader, err := tlsutil.NewCertReloader(certPairs)
+		if err != nil {
+			logger.Fatal("Failed to load TLS certificates", logging.Fields{"error": err.Error()})
+		}
+		tlsConfig, err := tlsutil.ServerConfig(cfg, reloader)
+		if err != nil {
+			logger.Fatal("Failed to configure TLS", logging.Fields{"error": err.Error()})
+		}
+		srv.TLSConfig = tlsConfig
+
+		go reloader.Watch(time.Duration(cfg.TLSReloadInterval)*time.Second, stopWatchers)
+	}
+
+	var redirectSrv *http.Server
+	if useTLS && cfg.HTTPRedirectPort != "" {
+		redirectSrv = &http.Server{
+			Addr:         ":" + cfg.HTTPRedirectPort,
+			Handler:      tlsutil.RedirectHandler(cfg.Port),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+
+		go func() {
+			logger.Info("HTTP redirect listener starting", logging.Fields{"port": cfg.HTTPRedirectPort})
+			if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Redirect listener failed to start", logging.Fields{"error": err.Error()})
+			}
+		}()
+	}
+
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminListener, err := listenAdmin(cfg.AdminAddr)
+		if err != nil {
+			logger.Fatal("Admin listener failed to start", logging.Fields{"error": err.Error()})
+		}
+		adminSrv = &http.Server{
+			Handler:      routes.SetupAdmin(h, routeMW, cfg),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 60 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+
+		go func() {
+			logger.Info("Admin listener starting", logging.Fields{"addr": cfg.AdminAddr})
+			if err := adminSrv.Serve(adminListener); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Admin listener failed", logging.Fields{"error": err.Error()})
+			}
+		}()
+	}
+
 	go func() {
 		logger.Info("Server starting", logging.Fields{
-			"port":              cfg.Port,
+			"port":            cfg.Port,
 			"enable_new_auth": cfg.EnableNewAuth,
-			"enable_v1_api":     cfg.EnableV1API,
+			"enable_v1_api":   cfg.EnableV1API,
+			"tls":             useTLS,
+			"mtls":            cfg.TLSClientCAFile != "",
 		})
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server failed to start", logging.Fields{"error": err.Error()})
 		}
 	}()
@@ -53,14 +249,127 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// USER-036: Drain before closing the listener. Readiness fails first so
+	// the load balancer stops sending traffic, then in-flight requests
+	// (including their upstream calls) get until the deadline to finish.
+	logger.Info("Draining server", logging.Fields{
+		"prestop_delay_seconds": cfg.ShutdownPreStopDelay,
+		"timeout_seconds":       cfg.ShutdownTimeout,
+	})
+
+	h.Health.SetDraining(true)
+	// Responses now carry "Connection: close" and idle keep-alive
+	// connections are dropped, so clients reconnect to other instances.
+	srv.SetKeepAlivesEnabled(false)
+
+	time.Sleep(time.Duration(cfg.ShutdownPreStopDelay) * time.Second)
+
 	logger.Info("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
 	defer cancel()
 
+	// Shutdown sends HTTP/2 GOAWAY so multiplexed streams reconnect elsewhere.
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", logging.Fields{"error": err.Error()})
+		srv.Close()
+	}
+
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	if adminSrv != nil {
+		adminSrv.Shutdown(ctx)
+	}
+	close(stopWatchers)
+	rateLimitMiddleware.Stop()
+	authMiddleware.Stop()
+	if err := v1Usage.Flush(); err != nil {
+		logger.Error("Failed to persist v1 usage", logging.Fields{"error": err.Error()})
 	}
 
 	logger.Info("Server exited")
 }
+
+// listenAdmin opens the admin listener on a TCP address or, with a "unix:"
+// prefix, on a unix socket.
+func listenAdmin(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// deliverWebhook forwards queued webhooks to the payments service. A 4xx
+// other than 408/429 means the payload itself was refused, so it is
+// dead-lettered instead of retried.
+func deliverWebhook(client *proxy.Client) webhookqueue.DeliverFunc {
+	return func(ctx context.Context, msg *webhookqueue.Message) error {
+		_, status, err := client.ProxyToPayments(ctx, "POST", "/api/v2/payments/webhook", msg.Payload)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status < 300:
+			return nil
+		case status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests:
+			return webhookqueue.Permanent(fmt.Errorf("payments service returned %d", status))
+		default:
+			return fmt.Errorf("payments service returned %d", status)
+		}
+	}
+}
+
+// USER-042: "gateway openapi" writes the generated OpenAPI document so CI
+// can diff it against the committed copy.
+func runOpenAPI(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	out := fs.String("o", "", "write the document to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	schemas, err := schema.LoadDir(cfg.SchemaDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load schemas: %v\n", err)
+		return 1
+	}
+
+	composites, err := compose.Load(cfg.CompositesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load composite endpoints: %v\n", err)
+		return 1
+	}
+
+	h := handlers.NewHandlers(proxy.NewClient(cfg), cfg, handlers.Deps{Composites: composites})
+	data, err := json.MarshalIndent(routes.OpenAPI(h, cfg, schemas), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode document: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write document: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,254 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyPrefix is prepended to every issued key so leaked keys are easy to spot.
+const KeyPrefix = "acme"
+
+var (
+	ErrInvalidKey = errors.New("invalid api key")
+	ErrNotFound   = errors.New("api key not found")
+	ErrExpired    = errors.New("api key expired")
+	ErrRevoked    = errors.New("api key revoked")
+
+	ErrScopesRequired = errors.New("api key scopes are required")
+)
+
+// Key is a stored API key. Only the SHA-256 hash of the secret is kept;
+// the plaintext is returned once from Create and never persisted. Keys
+// must list their Scopes ("*" grants everything the role allows); a key
+// with none, including one stored before scopes were required, is denied
+// everything.
+type Key struct {
+	ID           string     `json:"id"`
+	Hash         string     `json:"hash"`
+	Owner        string     `json:"owner"`
+	Role         string     `json:"role"`
+	Scopes       []string   `json:"scopes"`
+	RateLimitRPS int        `json:"rate_limit_rps,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k *Key) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// CreateRequest describes a new key.
+type CreateRequest struct {
+	Owner        string
+	Role         string
+	Scopes       []string
+	RateLimitRPS int
+	TTL          time.Duration
+}
+
+// Store holds API keys in memory and persists them to a JSON file.
+type Store struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+// NewStore loads keys from path. A missing file yields an empty store;
+// an empty path keeps keys in memory only.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		keys: make(map[string]*Key),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api key store: %w", err)
+	}
+
+	var keys []*Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse api key store: %w", err)
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+
+	return s, nil
+}
+
+// Create issues a new key and returns a copy of the stored record together
+// with the plaintext key, which is shown to the caller exactly once.
+func (s *Store) Create(req CreateRequest) (*Key, string, error) {
+	if len(req.Scopes) == 0 {
+		return nil, "", ErrScopesRequired
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	key := &Key{
+		ID:           id,
+		Hash:         hashSecret(secret),
+		Owner:        req.Owner,
+		Role:         req.Role,
+		Scopes:       req.Scopes,
+		RateLimitRPS: req.RateLimitRPS,
+		CreatedAt:    now,
+	}
+	if req.TTL > 0 {
+		expires := now.Add(req.TTL)
+		key.ExpiresAt = &expires
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return nil, "", err
+	}
+
+	copied := *key
+	return &copied, KeyPrefix + "_" + id + "_" + secret, nil
+}
+
+// Verify checks a plaintext key and returns a copy of its record.
+func (s *Store) Verify(raw string) (*Key, error) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != KeyPrefix {
+		return nil, ErrInvalidKey
+	}
+
+	// Revoke updates keys in place, so they are only read under the lock.
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[parts[1]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(parts[2]))) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	if !key.Active(time.Now()) {
+		return nil, ErrExpired
+	}
+
+	copied := *key
+	return &copied, nil
+}
+
+// List returns copies of all keys ordered by creation time.
+func (s *Store) List() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		copied := *k
+		keys = append(keys, &copied)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Revoke marks a key as revoked. Revoked keys are kept for auditing.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	if err := s.save(); err != nil {
+		key.RevokedAt = nil
+		return err
+	}
+	return nil
+}
+
+// save writes the store to disk. Callers must hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode api key store: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write api key store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write api key store: %w", err)
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,128 @@
+package apikey_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+)
+
+func TestStore_CreateAndVerify(t *testing.T) {
+	store, err := apikey.NewStore("")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	key, raw, err := store.Create(apikey.CreateRequest{Owner: "partner-1", Scopes: []string{"orders:read"}})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	got, err := store.Verify(raw)
+	if err != nil {
+		t.Fatalf("failed to verify key: %v", err)
+	}
+
+	if got.ID != key.ID {
+		t.Errorf("expected key ID '%s', got '%s'", key.ID, got.ID)
+	}
+
+	if _, err := store.Verify(raw + "x"); err == nil {
+		t.Error("expected error for tampered key")
+	}
+}
+
+func TestStore_RevokeAndExpiry(t *testing.T) {
+	store, _ := apikey.NewStore("")
+
+	key, raw, _ := store.Create(apikey.CreateRequest{Owner: "batch", Scopes: []string{"orders:read"}})
+	if err := store.Revoke(key.ID); err != nil {
+		t.Fatalf("failed to revoke key: %v", err)
+	}
+	if _, err := store.Verify(raw); err != apikey.ErrRevoked {
+		t.Errorf("expected ErrRevoked, got %v", err)
+	}
+
+	_, raw, _ = store.Create(apikey.CreateRequest{Owner: "batch", Scopes: []string{"orders:read"}, TTL: time.Nanosecond})
+	time.Sleep(time.Millisecond)
+	if _, err := store.Verify(raw); err != apikey.ErrExpired {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	store, _ := apikey.NewStore(path)
+	_, raw, err := store.Create(apikey.CreateRequest{Owner: "partner-2", Scopes: []string{"orders:read"}})
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	reloaded, err := apikey.NewStore(path)
+	if err != nil {
+		t.Fatalf("failed to reload store: %v", err)
+	}
+
+	if _, err := reloaded.Verify(raw); err != nil {
+		t.Errorf("expected key to survive reload, got %v", err)
+	}
+}
+
+func TestStore_CreateRequiresScopes(t *testing.T) {
+	store, _ := apikey.NewStore("")
+
+	for _, scopes := range [][]string{nil, {}} {
+		if _, _, err := store.Create(apikey.CreateRequest{Owner: "partner-3", Scopes: scopes}); err != apikey.ErrScopesRequired {
+			t.Errorf("expected ErrScopesRequired for %#v, got %v", scopes, err)
+		}
+	}
+	if len(store.List()) != 0 {
+		t.Error("expected no keys to be stored")
+	}
+}
+
+// Run with -race: Verify and List must not read keys Revoke is updating.
+func TestStore_ConcurrentVerifyAndRevoke(t *testing.T) {
+	store, _ := apikey.NewStore("")
+
+	ids := make([]string, 20)
+	raws := make([]string, len(ids))
+	for i := range ids {
+		key, raw, err := store.Create(apikey.CreateRequest{Owner: "partner", Scopes: []string{"orders:read"}})
+		if err != nil {
+			t.Fatalf("failed to create key: %v", err)
+		}
+		ids[i], raws[i] = key.ID, raw
+	}
+
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(3)
+		go func(id string) {
+			defer wg.Done()
+			store.Revoke(id)
+		}(ids[i])
+		go func(raw string) {
+			defer wg.Done()
+			if key, err := store.Verify(raw); err == nil && key.RevokedAt != nil {
+				t.Error("Verify returned a revoked key")
+			}
+		}(raws[i])
+		go func() {
+			defer wg.Done()
+			for _, key := range store.List() {
+				_ = key.RevokedAt
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, raw := range raws {
+		if _, err := store.Verify(raw); err != apikey.ErrRevoked {
+			t.Errorf("expected ErrRevoked, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Event types emitted to the security audit trail.
+const (
+	EventBypassUsed    = "auth_bypass_used"
+	EventBypassDenied  = "auth_bypass_denied"
+	EventBypassExpired = "auth_bypass_expired"
+
+	EventImpersonationStarted      = "impersonation_started"
+	EventImpersonatedRequest       = "impersonated_request"
+	EventImpersonationWriteBlocked = "impersonation_write_blocked"
+)
+
+// Log writes a structured security audit event. The request ID is taken
+// from ctx when the correlation middleware has set it.
+func Log(ctx context.Context, event string, fields logging.Fields) {
+	entry := logging.Fields{
+		"audit":     true,
+		"event":     event,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	if requestID, ok := ctx.Value(logging.ContextKeyRequestID).(string); ok && requestID != "" {
+		entry["request_id"] = requestID
+	}
+	for k, v := range fields {
+		entry[k] = v
+	}
+
+	logging.Warn("Security audit event", entry)
+}
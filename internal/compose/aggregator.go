@@ -0,0 +1,311 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+)
+
+// Service performs a request against one upstream.
+type Service func(ctx context.Context, method, path string, body interface{}) ([]byte, int, error)
+
+// Failure marks a call that produced no usable response.
+type Failure struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// CallError is returned by Execute when a required call fails. Body holds
+// the upstream response when the call completed with a non-2xx status.
+type CallError struct {
+	Call    string
+	Failure Failure
+	Body    []byte
+	Err     error
+}
+
+func (e *CallError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("call %s failed: %v", e.Call, e.Err)
+	}
+	return fmt.Sprintf("call %s failed: %s (%d)", e.Call, e.Failure.Error, e.Failure.Status)
+}
+
+func (e *CallError) Unwrap() error { return e.Err }
+
+// Vars are the request values placeholders can reference.
+type Vars struct {
+	Path  map[string]string
+	Query url.Values
+}
+
+// Result is an assembled response. Errors lists failed optional calls.
+type Result struct {
+	Body   map[string]interface{}
+	Errors map[string]Failure
+}
+
+// Aggregator executes composite endpoints against the upstream services.
+type Aggregator struct {
+	services map[string]Service
+	allowed  func(ctx context.Context, perm string) bool
+}
+
+// NewAggregator calls upstreams through client. allowed decides whether the
+// caller holds a call's Permission.
+func NewAggregator(client *proxy.Client, allowed func(ctx context.Context, perm string) bool) *Aggregator {
+	return &Aggregator{
+		services: map[string]Service{
+			"users":         client.ProxyToUsers,
+			"orders":        client.ProxyToOrders,
+			"payments":      client.ProxyToPayments,
+			"notifications": client.ProxyToNotifications,
+		},
+		allowed: allowed,
+	}
+}
+
+// outcome is the state of one call during execution. done is closed once
+// value or failure is set.
+type outcome struct {
+	done    chan struct{}
+	value   interface{}
+	body    []byte
+	err     error
+	failure *Failure
+}
+
+// Execute runs ep's calls, each as soon as the calls it references have
+// finished, and renders the template. A failed required call cancels the
+// remaining calls and is returned as a *CallError.
+func (a *Aggregator) Execute(ctx context.Context, ep *Endpoint, vars Vars) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make(map[string]*outcome, len(ep.Calls))
+	for _, c := range ep.Calls {
+		outcomes[c.Name] = &outcome{done: make(chan struct{})}
+	}
+
+	limit := ep.Concurrency
+	if limit <= 0 {
+		limit = len(ep.Calls)
+	}
+	sem := make(chan struct{}, limit)
+
+	for _, c := range ep.Calls {
+		go func(c *Call) {
+			out := outcomes[c.Name]
+			defer close(out.done)
+
+			for _, dep := range c.deps {
+				<-outcomes[dep].done
+				if outcomes[dep].failure != nil {
+					out.failure = &Failure{Error: "dependency_failed", Status: http.StatusFailedDependency}
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				out.failure = &Failure{Error: "cancelled", Status: http.StatusServiceUnavailable}
+				return
+			}
+
+			a.run(ctx, c, vars, outcomes, out)
+			if out.failure != nil && !c.Optional {
+				cancel()
+			}
+		}(c)
+	}
+
+	result := &Result{Errors: make(map[string]Failure)}
+	for _, c := range ep.Calls {
+		out := outcomes[c.Name]
+		<-out.done
+		if out.failure == nil {
+			continue
+		}
+		if !c.Optional {
+			// Wait for the rest so no goroutine outlives the request.
+			for _, other := range outcomes {
+				<-other.done
+			}
+			return nil, &CallError{Call: c.Name, Failure: *out.failure, Body: out.body, Err: out.err}
+		}
+		result.Errors[c.Name] = *out.failure
+	}
+
+	lookup := func(ref string) interface{} {
+		return resolve(ctx, ref, vars, outcomes)
+	}
+	result.Body = render(ep.template, lookup).(map[string]interface{})
+
+	return result, nil
+}
+
+func (a *Aggregator) run(ctx context.Context, c *Call, vars Vars, outcomes map[string]*outcome, out *outcome) {
+	if c.Permission != "" && !a.allowed(ctx, c.Permission) {
+		out.failure = &Failure{Error: "forbidden", Status: http.StatusForbidden}
+		return
+	}
+
+	path, ok := expandPath(c.Path, func(ref string) interface{} {
+		return resolve(ctx, ref, vars, outcomes)
+	})
+	if !ok {
+		out.failure = &Failure{Error: "missing_input", Status: http.StatusBadGateway}
+		return
+	}
+
+	if timeout := c.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	body, status, err := a.services[c.Service](ctx, "GET", path, nil)
+	out.err = err
+	switch {
+	case errors.Is(err, proxy.ErrDeadlineExceeded):
+		out.failure = &Failure{Error: "timeout", Status: http.StatusGatewayTimeout}
+	case err != nil:
+		out.failure = &Failure{Error: "unavailable", Status: http.StatusServiceUnavailable}
+	case status == http.StatusNotFound:
+		out.failure = &Failure{Error: "not_found", Status: status}
+		out.body = body
+	case status < 200 || status >= 300:
+		out.failure = &Failure{Error: "upstream_error", Status: status}
+		out.body = body
+	default:
+		if err := json.Unmarshal(body, &out.value); err != nil {
+			out.failure = &Failure{Error: "invalid_response", Status: http.StatusBadGateway}
+		}
+	}
+	if out.failure != nil {
+		out.value = nil
+	}
+}
+
+// resolve looks up a placeholder reference such as "order.items.0.id".
+// Callers only resolve references to calls that have finished.
+func resolve(ctx context.Context, ref string, vars Vars, outcomes map[string]*outcome) interface{} {
+	root, rest, _ := strings.Cut(ref, ".")
+	switch root {
+	case sourcePath:
+		if v, ok := vars.Path[rest]; ok {
+			return v
+		}
+		return nil
+	case sourceQuery:
+		if vars.Query.Has(rest) {
+			return vars.Query.Get(rest)
+		}
+		return nil
+	case sourceCaller:
+		if rest == "role" {
+			return middleware.GetRoleFromContext(ctx)
+		}
+		return middleware.GetUserIDFromContext(ctx)
+	}
+
+	v := outcomes[root].value
+	if rest == "" {
+		return v
+	}
+	for _, seg := range strings.Split(rest, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// expandPath substitutes placeholders in an upstream path, escaping values
+// for the path or query component they land in. It reports false when a
+// referenced value is missing or not a scalar.
+func expandPath(path string, lookup func(string) interface{}) (string, bool) {
+	ok := true
+	queryStart := strings.IndexByte(path, '?')
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(path, -1) {
+		b.WriteString(path[last:loc[0]])
+		last = loc[1]
+
+		s, scalar := scalarString(lookup(path[loc[2]:loc[3]]))
+		if !scalar || s == "" {
+			ok = false
+			continue
+		}
+		if queryStart >= 0 && loc[0] > queryStart {
+			b.WriteString(url.QueryEscape(s))
+		} else {
+			b.WriteString(url.PathEscape(s))
+		}
+	}
+	b.WriteString(path[last:])
+
+	return b.String(), ok
+}
+
+// render copies the template, substituting placeholders.
+func render(v interface{}, lookup func(string) interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if m := placeholder.FindStringSubmatch(val); m != nil && m[0] == val {
+			return lookup(m[1])
+		}
+		return placeholder.ReplaceAllStringFunc(val, func(p string) string {
+			s, _ := scalarString(lookup(p[2 : len(p)-1]))
+			return s
+		})
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = render(child, lookup)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = render(child, lookup)
+		}
+		return out
+	}
+	return v
+}
+
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(val), true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
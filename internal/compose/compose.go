@@ -0,0 +1,265 @@
+package compose
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Services that calls may target.
+var services = map[string]bool{"users": true, "orders": true, "payments": true, "notifications": true}
+
+// Placeholder sources other than call names. "path" and "query" read the
+// incoming request; "caller" exposes user_id and role.
+const (
+	sourcePath   = "path"
+	sourceQuery  = "query"
+	sourceCaller = "caller"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\$\{([A-Za-z0-9_.\-]+)\}`)
+	pathParam   = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+)
+
+// Call is one upstream request in a composite endpoint. Path may reference
+// ${path.<param>}, ${query.<name>}, ${caller.user_id} and fields of earlier
+// calls' responses as ${<call>.<field>...}; a call runs once every call it
+// references has finished. An Optional call that fails is reported in the
+// response's "errors" instead of failing the request. Permission, when set,
+// must be held by the caller or the call is skipped as forbidden.
+type Call struct {
+	Name       string `json:"name"`
+	Service    string `json:"service"`
+	Path       string `json:"path"`
+	TimeoutMS  int    `json:"timeout_ms,omitempty"`
+	Optional   bool   `json:"optional,omitempty"`
+	Permission string `json:"permission,omitempty"`
+
+	deps []string
+}
+
+// Timeout is the call's own limit; zero leaves only the service timeout and
+// the endpoint's budget.
+func (c *Call) Timeout() time.Duration {
+	return time.Duration(c.TimeoutMS) * time.Millisecond
+}
+
+// USER-045: Declarative composite endpoints
+// Endpoint is a gateway route whose response is assembled from several
+// upstream calls. Template is a JSON object in which any string of the form
+// "${ref}" is replaced by the referenced value (keeping its JSON type) and
+// other strings have references interpolated. Concurrency caps the calls in
+// flight at once; zero means no limit.
+type Endpoint struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Permission  string          `json:"permission,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	TimeoutMS   int             `json:"timeout_ms,omitempty"`
+	Concurrency int             `json:"concurrency,omitempty"`
+	Calls       []*Call         `json:"calls"`
+	Template    json.RawMessage `json:"template"`
+
+	template map[string]interface{}
+	params   []string
+}
+
+// Pattern returns the ServeMux pattern for the endpoint.
+func (e *Endpoint) Pattern() string {
+	return e.Method + " " + e.Path
+}
+
+// Timeout is the endpoint's total budget; zero uses the gateway default.
+func (e *Endpoint) Timeout() time.Duration {
+	return time.Duration(e.TimeoutMS) * time.Millisecond
+}
+
+// Params returns the names of the path wildcards in Path.
+func (e *Endpoint) Params() []string {
+	return e.params
+}
+
+// Load reads composite endpoint definitions from a JSON file. A missing
+// file yields no endpoints.
+func Load(path string) ([]*Endpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read composite endpoints: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes and checks composite endpoint definitions.
+func Parse(data []byte) ([]*Endpoint, error) {
+	var endpoints []*Endpoint
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse composite endpoints: %w", err)
+	}
+
+	for _, ep := range endpoints {
+		if err := ep.compile(); err != nil {
+			return nil, fmt.Errorf("composite endpoint %s: %w", ep.Pattern(), err)
+		}
+	}
+
+	return endpoints, nil
+}
+
+func (e *Endpoint) compile() error {
+	if e.Method == "" {
+		e.Method = "GET"
+	}
+	e.Method = strings.ToUpper(e.Method)
+	if !strings.HasPrefix(e.Path, "/") {
+		return errors.New("path must start with /")
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(e.Path, -1) {
+		e.params = append(e.params, m[1])
+	}
+	if e.Owner != "" && !contains(e.params, "id") {
+		return errors.New("owner requires an {id} path parameter")
+	}
+
+	if len(e.Calls) == 0 {
+		return errors.New("at least one call is required")
+	}
+	calls := make(map[string]*Call, len(e.Calls))
+	for _, c := range e.Calls {
+		switch {
+		case c.Name == "":
+			return errors.New("call name is required")
+		case c.Name == sourcePath || c.Name == sourceQuery || c.Name == sourceCaller || c.Name == "errors":
+			return fmt.Errorf("call name %q is reserved", c.Name)
+		case calls[c.Name] != nil:
+			return fmt.Errorf("duplicate call %q", c.Name)
+		case !services[c.Service]:
+			return fmt.Errorf("call %q: unknown service %q", c.Name, c.Service)
+		case !strings.HasPrefix(c.Path, "/"):
+			return fmt.Errorf("call %q: path must start with /", c.Name)
+		}
+		calls[c.Name] = c
+	}
+
+	for _, c := range e.Calls {
+		refs, err := e.refs(c.Path, calls)
+		if err != nil {
+			return fmt.Errorf("call %q: %w", c.Name, err)
+		}
+		c.deps = refs
+	}
+	if err := checkCycles(e.Calls, calls); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(e.Template, &e.template); err != nil || e.template == nil {
+		return errors.New("template must be a JSON object")
+	}
+	var templateErr error
+	walkStrings(e.template, func(s string) {
+		if _, err := e.refs(s, calls); err != nil && templateErr == nil {
+			templateErr = fmt.Errorf("template: %w", err)
+		}
+	})
+	return templateErr
+}
+
+// refs returns the calls referenced by placeholders in s, checking that
+// every placeholder names a known source.
+func (e *Endpoint) refs(s string, calls map[string]*Call) ([]string, error) {
+	var deps []string
+	for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+		root, field, _ := strings.Cut(m[1], ".")
+		switch root {
+		case sourcePath:
+			if !contains(e.params, field) {
+				return nil, fmt.Errorf("unknown path parameter %q", field)
+			}
+		case sourceQuery:
+			if field == "" {
+				return nil, errors.New("query reference needs a parameter name")
+			}
+		case sourceCaller:
+			if field != "user_id" && field != "role" {
+				return nil, fmt.Errorf("unknown caller field %q", field)
+			}
+		default:
+			if calls[root] == nil {
+				return nil, fmt.Errorf("unknown reference %q", m[1])
+			}
+			if !contains(deps, root) {
+				deps = append(deps, root)
+			}
+		}
+	}
+	return deps, nil
+}
+
+func checkCycles(order []*Call, calls map[string]*Call) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(calls))
+
+	var visit func(c *Call) error
+	visit = func(c *Call) error {
+		switch state[c.Name] {
+		case visiting:
+			return fmt.Errorf("call %q depends on itself", c.Name)
+		case done:
+			return nil
+		}
+		state[c.Name] = visiting
+		for _, dep := range c.deps {
+			if err := visit(calls[dep]); err != nil {
+				return err
+			}
+		}
+		state[c.Name] = done
+		return nil
+	}
+
+	for _, c := range order {
+		if err := visit(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkStrings(v interface{}, fn func(string)) {
+	switch val := v.(type) {
+	case string:
+		fn(val)
+	case map[string]interface{}:
+		for _, child := range val {
+			walkStrings(child, fn)
+		}
+	case []interface{}:
+		for _, child := range val {
+			walkStrings(child, fn)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package compose_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/compose"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want string
+	}{
+		{"unknown service", `[{"path":"/v","calls":[{"name":"a","service":"carts","path":"/x"}],"template":{}}]`, "unknown service"},
+		{"unknown reference", `[{"path":"/v","calls":[{"name":"a","service":"users","path":"/x/${b.id}"}],"template":{}}]`, "unknown reference"},
+		{"cycle", `[{"path":"/v","calls":[{"name":"a","service":"users","path":"/x/${b.id}"},{"name":"b","service":"users","path":"/y/${a.id}"}],"template":{}}]`, "depends on itself"},
+		{"unknown path parameter", `[{"path":"/v/{id}","calls":[{"name":"a","service":"users","path":"/x/${path.key}"}],"template":{}}]`, "unknown path parameter"},
+		{"template not an object", `[{"path":"/v","calls":[{"name":"a","service":"users","path":"/x"}],"template":[]}]`, "template must be a JSON object"},
+		{"owner without id", `[{"path":"/v","owner":"orders","calls":[{"name":"a","service":"users","path":"/x"}],"template":{}}]`, "owner requires"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compose.Parse([]byte(tt.def))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAggregator_Execute(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		switch r.URL.RequestURI() {
+		case "/api/v2/payments/pay_1":
+			w.Write([]byte(`{"id":"pay_1","order_id":"ord 1","user_id":"user_1"}`))
+		case "/api/v2/orders/ord%201":
+			w.Write([]byte(`{"id":"ord 1","status":"paid","items":[{"sku":"A"}]}`))
+		case "/api/v2/users/user_1/notifications?since=2024":
+			w.Write([]byte(`[]`))
+		case "/api/v2/users/user_1":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		UsersServiceURL:         upstream.URL,
+		OrdersServiceURL:        upstream.URL,
+		PaymentsServiceURL:      upstream.URL,
+		NotificationsServiceURL: upstream.URL,
+		RequestTimeout:          5,
+	}
+	agg := compose.NewAggregator(proxy.NewClient(cfg), func(ctx context.Context, perm string) bool {
+		return perm != "notifications:admin"
+	})
+
+	endpoints, err := compose.Parse([]byte(`[{
+		"path": "/api/v2/views/payments/{id}",
+		"concurrency": 1,
+		"calls": [
+			{"name": "payment", "service": "payments", "path": "/api/v2/payments/${path.id}"},
+			{"name": "order", "service": "orders", "path": "/api/v2/orders/${payment.order_id}"},
+			{"name": "user", "service": "users", "path": "/api/v2/users/${payment.user_id}", "optional": true},
+			{"name": "inbox", "service": "notifications", "path": "/api/v2/users/${payment.user_id}/notifications?since=${query.since}", "optional": true},
+			{"name": "audit", "service": "notifications", "path": "/audit", "optional": true, "permission": "notifications:admin"}
+		],
+		"template": {
+			"payment": "${payment.id}",
+			"first_sku": "${order.items.0.sku}",
+			"summary": "Order ${order.id} is ${order.status}",
+			"customer": "${user}",
+			"inbox": "${inbox}"
+		}
+	}]`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	vars := compose.Vars{Path: map[string]string{"id": "pay_1"}, Query: map[string][]string{"since": {"2024"}}}
+	result, err := agg.Execute(context.Background(), endpoints[0], vars)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"payment":   "pay_1",
+		"first_sku": "A",
+		"summary":   "Order ord 1 is paid",
+		"customer":  nil,
+	}
+	for key, value := range want {
+		if result.Body[key] != value {
+			t.Errorf("expected %s = %v, got %v", key, value, result.Body[key])
+		}
+	}
+	if inbox, ok := result.Body["inbox"].([]interface{}); !ok || len(inbox) != 0 {
+		t.Errorf("expected empty inbox list, got %v", result.Body["inbox"])
+	}
+
+	if e := result.Errors["user"]; e.Error != "upstream_error" || e.Status != http.StatusInternalServerError {
+		t.Errorf("expected user upstream_error, got %+v", e)
+	}
+	if e := result.Errors["audit"]; e.Error != "forbidden" {
+		t.Errorf("expected audit forbidden, got %+v", e)
+	}
+	if n := maxInFlight.Load(); n != 1 {
+		t.Errorf("expected at most 1 call in flight, got %d", n)
+	}
+
+	// A failed required call fails the whole request.
+	vars.Path["id"] = "pay_missing"
+	_, err = agg.Execute(context.Background(), endpoints[0], vars)
+	var callErr *compose.CallError
+	if !errors.As(err, &callErr) || callErr.Call != "payment" || callErr.Failure.Status != http.StatusNotFound {
+		t.Errorf("expected payment not_found CallError, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -16,6 +18,60 @@ type Config struct {
 	EnableV1API             bool
 	RateLimitRPS            int
 	RequestTimeout          int
+	RouteTimeouts           []string
+	UsersServiceTimeout     int
+	OrdersServiceTimeout    int
+	PaymentsServiceTimeout  int
+	NotificationsTimeout    int
+	APIKeyStorePath         string
+	OAuthClientsPath        string
+	OAuthTokenTTL           int
+	PolicyPath              string
+	OwnershipCacheTTL       int
+	LegacyBypassPath        string
+	LegacyAuthAllowedCIDRs  []string
+	V1EnforceJWTRoutes      []string
+	ImpersonationTTL        int
+	ImpersonationReadOnly   bool
+	TLSCertFile             string
+	TLSKeyFile              string
+	TLSClientCAFile         string
+	MTLSIdentitiesPath      string
+	TLSCertificates         []string
+	TLSMinVersion           string
+	TLSCipherSuites         []string
+	TLSReloadInterval       int
+	HTTPRedirectPort        string
+	AdminAddr               string
+	AdminToken              string
+	ShutdownPreStopDelay    int
+	ShutdownTimeout         int
+	IdempotencyRoutes       []string
+	IdempotencyTTL          int
+	WebhookSecrets          []string
+	WebhookTolerance        int
+	WebhookQueueDir         string
+	WebhookMaxAttempts      int
+	CompositesPath          string
+	GraphQLMaxDepth         int
+	GraphQLMaxComplexity    int
+	BatchMaxItems           int
+	BatchConcurrency        int
+	V1TranslationsPath      string
+	V1DeprecationDate       string
+	V1SunsetDate            string
+	V1MigrationDocsURL      string
+	SunsetEnforce           bool
+	SunsetBrownouts         []string
+	V1UsagePath             string
+	V1UsageFlushInterval    int
+	V1UsageTrustedProxies   []string
+	SchemaDir               string
+	SchemaValidationMode    string
+	SchemaReportOnly        []string
+
+	ResponseValidationMode string
+	ResponseSampleKeepKeys []string
 }
 
 func Load() *Config {
@@ -30,7 +86,87 @@ func Load() *Config {
 		EnableV1API:             getEnvBool("ENABLE_V1_API", true),
 		RateLimitRPS:            getEnvInt("RATE_LIMIT_RPS", 100),
 		RequestTimeout:          getEnvInt("REQUEST_TIMEOUT_SECONDS", 30),
+		RouteTimeouts:           getEnvList("ROUTE_TIMEOUTS", ""),
+		UsersServiceTimeout:     getEnvInt("USERS_SERVICE_TIMEOUT_SECONDS", 0),
+		OrdersServiceTimeout:    getEnvInt("ORDERS_SERVICE_TIMEOUT_SECONDS", 0),
+		PaymentsServiceTimeout:  getEnvInt("PAYMENTS_SERVICE_TIMEOUT_SECONDS", 0),
+		NotificationsTimeout:    getEnvInt("NOTIFICATIONS_SERVICE_TIMEOUT_SECONDS", 0),
+		APIKeyStorePath:         getEnv("API_KEY_STORE_PATH", "configs/api_keys.json"),
+		OAuthClientsPath:        getEnv("OAUTH_CLIENTS_PATH", "configs/oauth_clients.json"),
+		OAuthTokenTTL:           getEnvInt("OAUTH_TOKEN_TTL_SECONDS", 3600),
+		PolicyPath:              getEnv("POLICY_PATH", "configs/policy.json"),
+		OwnershipCacheTTL:       getEnvInt("OWNERSHIP_CACHE_TTL_SECONDS", 30),
+		LegacyBypassPath:        getEnv("LEGACY_BYPASS_PATH", "configs/legacy_bypass.json"),
+		LegacyAuthAllowedCIDRs:  getEnvList("LEGACY_AUTH_ALLOWED_CIDRS", ""),
+		V1EnforceJWTRoutes:      getEnvList("V1_ENFORCE_JWT_ROUTES", ""),
+		ImpersonationTTL:        getEnvInt("IMPERSONATION_TTL_SECONDS", 900),
+		ImpersonationReadOnly:   getEnvBool("IMPERSONATION_READ_ONLY", true),
+		TLSCertFile:             getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:              getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:         getEnv("TLS_CLIENT_CA_FILE", ""),
+		MTLSIdentitiesPath:      getEnv("MTLS_IDENTITIES_PATH", "configs/mtls_identities.json"),
+		TLSCertificates:         getEnvList("TLS_CERTIFICATES", ""),
+		TLSMinVersion:           getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites:         getEnvList("TLS_CIPHER_SUITES", ""),
+		TLSReloadInterval:       getEnvInt("TLS_RELOAD_INTERVAL_SECONDS", 30),
+		HTTPRedirectPort:        getEnv("HTTP_REDIRECT_PORT", ""),
+		AdminAddr:               getEnv("ADMIN_ADDR", ":9090"),
+		AdminToken:              getEnv("ADMIN_TOKEN", ""),
+		ShutdownPreStopDelay:    getEnvInt("SHUTDOWN_PRESTOP_DELAY_SECONDS", 10),
+		ShutdownTimeout:         getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30),
+		IdempotencyRoutes:       getEnvList("IDEMPOTENCY_ROUTES", "POST /api/v2/orders,POST /api/v2/payments,POST /api/v1/payments"),
+		IdempotencyTTL:          getEnvInt("IDEMPOTENCY_TTL_SECONDS", 86400),
+		WebhookSecrets:          getEnvList("WEBHOOK_SECRETS", ""),
+		WebhookTolerance:        getEnvInt("WEBHOOK_TOLERANCE_SECONDS", 300),
+		WebhookQueueDir:         getEnv("WEBHOOK_QUEUE_DIR", "data/webhooks"),
+		WebhookMaxAttempts:      getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		CompositesPath:          getEnv("COMPOSITES_PATH", "configs/composites.json"),
+		GraphQLMaxDepth:         getEnvInt("GRAPHQL_MAX_DEPTH", 6),
+		GraphQLMaxComplexity:    getEnvInt("GRAPHQL_MAX_COMPLEXITY", 250),
+		BatchMaxItems:           getEnvInt("BATCH_MAX_ITEMS", 100),
+		BatchConcurrency:        getEnvInt("BATCH_CONCURRENCY", 10),
+		V1TranslationsPath:      getEnv("V1_TRANSLATIONS_PATH", "configs/v1_translations.json"),
+		V1DeprecationDate:       getEnv("V1_DEPRECATION_DATE", ""),
+		V1SunsetDate:            getEnv("V1_SUNSET_DATE", "2024-06-01"),
+		V1MigrationDocsURL:      getEnv("V1_MIGRATION_DOCS_URL", ""),
+		SunsetEnforce:           getEnvBool("SUNSET_ENFORCE", false),
+		SunsetBrownouts:         getEnvList("SUNSET_BROWNOUTS", ""),
+		V1UsagePath:             getEnv("V1_USAGE_PATH", "data/v1_usage.json"),
+		V1UsageFlushInterval:    getEnvInt("V1_USAGE_FLUSH_SECONDS", 30),
+		V1UsageTrustedProxies:   getEnvList("V1_USAGE_TRUSTED_PROXY_CIDRS", ""),
+		SchemaDir:               getEnv("SCHEMA_DIR", "configs/schemas"),
+		SchemaValidationMode:    getEnv("SCHEMA_VALIDATION_MODE", "enforce"),
+		SchemaReportOnly:        getEnvList("SCHEMA_REPORT_ONLY", ""),
+
+		ResponseValidationMode: getEnv("RESPONSE_VALIDATION_MODE", "off"),
+		ResponseSampleKeepKeys: getEnvList("RESPONSE_SAMPLE_KEEP_KEYS", ""),
+	}
+}
+
+// Validate rejects settings Load cannot interpret, which would otherwise
+// silently fall back to some other behaviour.
+func (c *Config) Validate() error {
+	switch c.SchemaValidationMode {
+	case "enforce", "report", "off":
+	default:
+		return fmt.Errorf("SCHEMA_VALIDATION_MODE must be enforce, report or off, got %q", c.SchemaValidationMode)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration safe to expose on admin endpoints.
+func (c *Config) Redacted() Config {
+	redacted := *c
+	if redacted.JWTSecret != "" {
+		redacted.JWTSecret = "[REDACTED]"
+	}
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = "[REDACTED]"
 	}
+	if len(redacted.WebhookSecrets) > 0 {
+		redacted.WebhookSecrets = []string{"[REDACTED]"}
+	}
+	return redacted
 }
 
 func getEnv(key, defaultValue string) string {
@@ -61,3 +197,14 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma-separated variable, dropping empty items.
+func getEnvList(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,586 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+)
+
+// Error codes reported in an error's extensions.
+const (
+	CodeInvalidQuery  = "GRAPHQL_VALIDATION_FAILED"
+	CodeLimitExceeded = "QUERY_TOO_COMPLEX"
+	CodeForbidden     = "FORBIDDEN"
+	CodeNotFound      = "NOT_FOUND"
+	CodeTimeout       = "TIMEOUT"
+	CodeUnavailable   = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamError = "UPSTREAM_ERROR"
+)
+
+// listFactor is the assumed size of a list when estimating query cost.
+const listFactor = 10
+
+// fetchCost is the cost of a field that calls an upstream.
+const fetchCost = 5
+
+// maxConcurrentFetches bounds the upstream calls one request has in flight.
+const maxConcurrentFetches = 8
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is a GraphQL result. Data is nil when the request failed before
+// execution started.
+type Response struct {
+	Data   *Object  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type Error struct {
+	Message    string            `json:"message"`
+	Locations  []Location        `json:"locations,omitempty"`
+	Path       []interface{}     `json:"path,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+func newError(code, message string, f *Field) *Error {
+	e := &Error{Message: message, Extensions: map[string]string{"code": code}}
+	if f != nil {
+		e.Locations = []Location{{Line: f.Line, Column: f.Column}}
+	}
+	return e
+}
+
+// Object is a result object that keeps fields in selection order.
+type Object struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get returns the value under key.
+func (o *Object) Get(key string) interface{} {
+	for i, k := range o.keys {
+		if k == key {
+			return o.values[i]
+		}
+	}
+	return nil
+}
+
+// Limits bounds the queries an Executor accepts. Zero disables a limit.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type service func(ctx context.Context, method, path string, body interface{}) ([]byte, int, error)
+
+// USER-046: GraphQL facade over the REST upstreams
+// Executor runs queries against the users, orders, payments and
+// notifications services. Sibling fields and list elements are resolved
+// concurrently through a per-request loader, which fetches each upstream
+// path once and caps the calls in flight. The upstreams have no batch
+// lookups, so a list of N orders costs up to N calls for each nested entity;
+// complexity charges that fan-out up front.
+type Executor struct {
+	services map[string]service
+	allowed  func(ctx context.Context, perm string) bool
+	limits   Limits
+	types    map[string]*typeDef
+}
+
+// NewExecutor resolves fields through client. allowed reports whether the
+// caller holds a permission.
+func NewExecutor(client *proxy.Client, allowed func(ctx context.Context, perm string) bool, limits Limits) *Executor {
+	return &Executor{
+		services: map[string]service{
+			"users":         client.ProxyToUsers,
+			"orders":        client.ProxyToOrders,
+			"payments":      client.ProxyToPayments,
+			"notifications": client.ProxyToNotifications,
+		},
+		allowed: allowed,
+		limits:  limits,
+		types:   newSchema(),
+	}
+}
+
+// Execute parses, validates and runs req.
+func (x *Executor) Execute(ctx context.Context, req Request) *Response {
+	ops, err := ParseWithDepth(req.Query, x.limits.MaxDepth)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		var depthErr *DepthError
+		e := newError(CodeInvalidQuery, err.Error(), nil)
+		if errors.As(err, &depthErr) {
+			e = newError(CodeLimitExceeded, err.Error(), nil)
+		} else if errors.As(err, &syntaxErr) {
+			e.Message = syntaxErr.Message
+			e.Locations = []Location{{Line: syntaxErr.Line, Column: syntaxErr.Column}}
+		}
+		return &Response{Errors: []*Error{e}}
+	}
+
+	op, err := selectOperation(ops, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{newError(CodeInvalidQuery, err.Error(), nil)}}
+	}
+
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) == 0 {
+		errs = x.validate(op)
+	}
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	e := &execution{
+		ctx:    ctx,
+		exec:   x,
+		vars:   vars,
+		loader: newLoader(),
+	}
+	data := e.executeObject(x.types["Query"], nil, op.Selections, nil)
+
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func selectOperation(ops []*Operation, name string) (*Operation, error) {
+	if name == "" {
+		if len(ops) > 1 {
+			return nil, errors.New("operationName is required when the document has several operations")
+		}
+		return ops[0], nil
+	}
+	for _, op := range ops {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(op *Operation, provided map[string]interface{}) (map[string]interface{}, []*Error) {
+	vars := make(map[string]interface{}, len(op.Variables))
+	var errs []*Error
+	for _, def := range op.Variables {
+		v, ok := provided[def.Name]
+		if !ok {
+			v = def.Default
+		}
+		if v == nil && def.Required {
+			errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("variable $%s of type %s is required", def.Name, def.Type), nil))
+			continue
+		}
+		vars[def.Name] = v
+	}
+	return vars, errs
+}
+
+// validate checks op against the schema and the executor's limits.
+func (x *Executor) validate(op *Operation) []*Error {
+	if op.Type != "query" {
+		return []*Error{newError(CodeInvalidQuery, "only query operations are supported", nil)}
+	}
+
+	declared := make(map[string]bool, len(op.Variables))
+	for _, def := range op.Variables {
+		declared[def.Name] = true
+	}
+
+	var errs []*Error
+	var check func(typ *typeDef, fields []*Field)
+	check = func(typ *typeDef, fields []*Field) {
+		seen := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			if seen[f.ResponseKey()] {
+				errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("field %q is selected more than once; use an alias", f.ResponseKey()), f))
+				continue
+			}
+			seen[f.ResponseKey()] = true
+
+			if f.Name == "__typename" {
+				if f.Selections != nil || f.Arguments != nil {
+					errs = append(errs, newError(CodeInvalidQuery, "__typename takes no arguments or selections", f))
+				}
+				continue
+			}
+
+			fd, ok := typ.fields[f.Name]
+			if !ok {
+				errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("cannot query field %q on type %q", f.Name, typ.name), f))
+				continue
+			}
+
+			for name, value := range f.Arguments {
+				if _, ok := fd.args[name]; !ok {
+					errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("unknown argument %q on field %q", name, f.Name), f))
+				}
+				if v, ok := value.(Variable); ok && !declared[string(v)] {
+					errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("variable $%s is not defined", v), f))
+				}
+			}
+			for name, arg := range fd.args {
+				if _, ok := f.Arguments[name]; arg.required && !ok {
+					errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("field %q requires argument %q", f.Name, name), f))
+				}
+			}
+
+			target := x.types[fd.typ]
+			switch {
+			case target == nil && f.Selections != nil:
+				errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("field %q of type %s has no subfields", f.Name, fd.typ), f))
+			case target != nil && f.Selections == nil:
+				errs = append(errs, newError(CodeInvalidQuery, fmt.Sprintf("field %q of type %s needs a selection of subfields", f.Name, fd.typ), f))
+			case target != nil:
+				check(target, f.Selections)
+			}
+		}
+	}
+	check(x.types["Query"], op.Selections)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if cost := x.complexity(x.types["Query"], op.Selections); x.limits.MaxComplexity > 0 && cost > x.limits.MaxComplexity {
+		return []*Error{newError(CodeLimitExceeded, fmt.Sprintf("query complexity %d exceeds the limit of %d", cost, x.limits.MaxComplexity), nil)}
+	}
+	return nil
+}
+
+// complexity counts one per field and fetchCost per field that calls an
+// upstream, multiplying the cost of a list's subfields by listFactor. A
+// lookup nested in a list is therefore charged once per element, matching
+// the calls it makes.
+func (x *Executor) complexity(typ *typeDef, fields []*Field) int {
+	cost := 0
+	for _, f := range fields {
+		fd, ok := typ.fields[f.Name]
+		if ok && fd.resolve != nil && !fd.embedded {
+			cost += fetchCost
+		} else {
+			cost++
+		}
+		if !ok || f.Selections == nil {
+			continue
+		}
+		sub := x.complexity(x.types[fd.typ], f.Selections)
+		if fd.list {
+			sub *= listFactor
+		}
+		cost += sub
+	}
+	return cost
+}
+
+// execution is the state of one request.
+type execution struct {
+	ctx    context.Context
+	exec   *Executor
+	vars   map[string]interface{}
+	loader *loader
+
+	mu     sync.Mutex
+	errors []*Error
+}
+
+func (e *execution) fail(err *Error, path []interface{}) {
+	err.Path = path
+	e.mu.Lock()
+	e.errors = append(e.errors, err)
+	e.mu.Unlock()
+}
+
+func (e *execution) executeObject(typ *typeDef, obj map[string]interface{}, fields []*Field, path []interface{}) *Object {
+	out := &Object{keys: make([]string, len(fields)), values: make([]interface{}, len(fields))}
+
+	var wg sync.WaitGroup
+	for i, f := range fields {
+		out.keys[i] = f.ResponseKey()
+		if f.Name == "__typename" {
+			out.values[i] = typ.name
+			continue
+		}
+		fd := typ.fields[f.Name]
+		if fd.resolve == nil {
+			out.values[i] = obj[f.Name]
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, f *Field) {
+			defer wg.Done()
+			out.values[i] = e.executeField(fd, obj, f, appendPath(path, f.ResponseKey()))
+		}(i, f)
+	}
+	wg.Wait()
+
+	return out
+}
+
+func (e *execution) executeField(fd *fieldDef, parent map[string]interface{}, f *Field, path []interface{}) interface{} {
+	target := e.exec.types[fd.typ]
+	if target != nil && target.resource != "" && !e.exec.allowed(e.ctx, target.resource+":read") {
+		e.fail(newError(CodeForbidden, "missing permission: "+target.resource+":read", f), path)
+		return nil
+	}
+
+	args, err := e.arguments(fd, f)
+	if err != nil {
+		e.fail(newError(CodeInvalidQuery, err.Error(), f), path)
+		return nil
+	}
+
+	value, err := fd.resolve(e, parent, args)
+	if err != nil {
+		e.fail(fieldErrorFor(err, f), path)
+		return nil
+	}
+	if value == nil || target == nil {
+		return value
+	}
+
+	if !fd.list {
+		return e.completeObject(target, value, f, path)
+	}
+
+	items, _ := value.([]interface{})
+	out := make([]interface{}, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			out[i] = e.completeObject(target, item, f, appendPath(path, i))
+		}(i, item)
+	}
+	wg.Wait()
+	return out
+}
+
+// completeObject checks ownership of an upstream object and resolves the
+// selected subfields.
+func (e *execution) completeObject(typ *typeDef, value interface{}, f *Field, path []interface{}) interface{} {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		if value != nil {
+			e.fail(newError(CodeUpstreamError, "upstream returned an unexpected "+typ.name, f), path)
+		}
+		return nil
+	}
+
+	if typ.owner != "" {
+		owner, _ := obj[typ.owner].(string)
+		caller := middleware.GetUserIDFromContext(e.ctx)
+		if (owner == "" || owner != caller) && !e.exec.allowed(e.ctx, typ.resource+":any") {
+			e.fail(newError(CodeNotFound, "not found", f), path)
+			return nil
+		}
+	}
+
+	return e.executeObject(typ, obj, f.Selections, path)
+}
+
+// arguments resolves variables in f's arguments and checks that IDs are
+// non-empty strings or numbers.
+func (e *execution) arguments(fd *fieldDef, f *Field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(f.Arguments))
+	for name, value := range f.Arguments {
+		if v, ok := value.(Variable); ok {
+			value = e.vars[string(v)]
+		}
+		if fd.args[name].typ == "ID" && idString(value) == "" {
+			if value != nil || fd.args[name].required {
+				return nil, fmt.Errorf("argument %q must be a non-empty ID", name)
+			}
+		}
+		args[name] = value
+	}
+	return args, nil
+}
+
+// caller returns the authenticated user ID for use in a URL path.
+func (e *execution) caller() (string, error) {
+	userID := middleware.GetUserIDFromContext(e.ctx)
+	if userID == "" {
+		return "", &fieldError{code: CodeForbidden, message: "no authenticated user"}
+	}
+	return url.PathEscape(userID), nil
+}
+
+// load fetches and decodes an upstream GET, sharing the result with any
+// other field that requests the same path.
+func (e *execution) load(svc, path string) (interface{}, error) {
+	return e.loader.load(e.ctx, e.exec.services[svc], svc+" "+path, path)
+}
+
+// loadRef loads prefix+id, or returns nil when the parent has no reference.
+func (e *execution) loadRef(svc, prefix string, id interface{}) (interface{}, error) {
+	if id == nil || id == "" {
+		return nil, nil
+	}
+	return e.load(svc, prefix+pathValue(id))
+}
+
+// loadList loads a collection returned either as a bare array or wrapped
+// in an object under key.
+func (e *execution) loadList(svc, path, key string) (interface{}, error) {
+	v, err := e.load(svc, path)
+	if err != nil {
+		return nil, err
+	}
+	switch list := v.(type) {
+	case []interface{}:
+		return list, nil
+	case map[string]interface{}:
+		if items, ok := list[key].([]interface{}); ok {
+			return items, nil
+		}
+	}
+	return nil, &fieldError{code: CodeUpstreamError, message: "upstream returned an unexpected list"}
+}
+
+type fieldError struct {
+	code    string
+	message string
+}
+
+func (e *fieldError) Error() string { return e.message }
+
+func fieldErrorFor(err error, f *Field) *Error {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return newError(fe.code, fe.message, f)
+	}
+	return newError(CodeUpstreamError, err.Error(), f)
+}
+
+// loader collapses concurrent and repeated requests for the same upstream
+// path within one query, and lets at most maxConcurrentFetches of them run
+// at once.
+type loader struct {
+	mu    sync.Mutex
+	calls map[string]*pendingCall
+	slots chan struct{}
+}
+
+func newLoader() *loader {
+	return &loader{
+		calls: make(map[string]*pendingCall),
+		slots: make(chan struct{}, maxConcurrentFetches),
+	}
+}
+
+type pendingCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+func (l *loader) load(ctx context.Context, call service, key, path string) (interface{}, error) {
+	l.mu.Lock()
+	if pc, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		<-pc.done
+		return pc.value, pc.err
+	}
+	pc := &pendingCall{done: make(chan struct{})}
+	l.calls[key] = pc
+	l.mu.Unlock()
+
+	select {
+	case l.slots <- struct{}{}:
+		pc.value, pc.err = fetch(ctx, call, path)
+		<-l.slots
+	case <-ctx.Done():
+		pc.err = &fieldError{code: CodeTimeout, message: "upstream timed out"}
+	}
+	close(pc.done)
+	return pc.value, pc.err
+}
+
+func fetch(ctx context.Context, call service, path string) (interface{}, error) {
+	body, status, err := call(ctx, "GET", path, nil)
+	switch {
+	case errors.Is(err, proxy.ErrDeadlineExceeded):
+		return nil, &fieldError{code: CodeTimeout, message: "upstream timed out"}
+	case err != nil:
+		return nil, &fieldError{code: CodeUnavailable, message: "upstream unavailable"}
+	case status == http.StatusNotFound:
+		return nil, &fieldError{code: CodeNotFound, message: "not found"}
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return nil, &fieldError{code: CodeForbidden, message: "upstream denied access"}
+	case status < 200 || status >= 300:
+		return nil, &fieldError{code: CodeUpstreamError, message: "upstream returned status " + strconv.Itoa(status)}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, &fieldError{code: CodeUpstreamError, message: "upstream returned invalid JSON"}
+	}
+	return value, nil
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	out := make([]interface{}, len(path)+1)
+	copy(out, path)
+	out[len(path)] = elem
+	return out
+}
+
+// pathValue formats an ID argument or field for use in a URL path.
+func pathValue(v interface{}) string {
+	return url.PathEscape(idString(v))
+}
+
+func queryValue(v interface{}) string {
+	return url.QueryEscape(idString(v))
+}
+
+func idString(v interface{}) string {
+	switch id := v.(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return ""
+}
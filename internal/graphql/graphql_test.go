@@ -0,0 +1,211 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/graphql"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+)
+
+func TestParse(t *testing.T) {
+	ops, err := graphql.Parse(`
+		# comment
+		query Order($id: ID!, $n: Int = 3) {
+			first: order(id: $id) { id status }
+			me { name }
+		}`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	op := ops[0]
+	if op.Name != "Order" || len(op.Variables) != 2 || !op.Variables[0].Required || op.Variables[1].Default != float64(3) {
+		t.Errorf("unexpected operation header: %+v", op)
+	}
+	first := op.Selections[0]
+	if first.Alias != "first" || first.Name != "order" || first.Arguments["id"] != graphql.Variable("id") || len(first.Selections) != 2 {
+		t.Errorf("unexpected field: %+v", first)
+	}
+
+	for _, query := range []string{`{ order(id: "1") { id }`, `{ ...F }`, `{ me @include(if: true) { id } }`, `{ me { name "x" } }`} {
+		if _, err := graphql.Parse(query); err == nil {
+			t.Errorf("expected syntax error for %q", query)
+		}
+	}
+
+	var depthErr *graphql.DepthError
+	if _, err := graphql.ParseWithDepth(`{ me { orders { user { id } } } }`, 3); !errors.As(err, &depthErr) {
+		t.Errorf("expected depth error, got %v", err)
+	}
+	if _, err := graphql.ParseWithDepth(`{ me { orders { id } } }`, 3); err != nil {
+		t.Errorf("unexpected error within the depth limit: %v", err)
+	}
+}
+
+type upstream struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	u.calls[r.URL.RequestURI()]++
+	u.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.RequestURI() {
+	case "/api/v2/users/user_1":
+		w.Write([]byte(`{"id":"user_1","name":"Ada","email":"ada@example.com"}`))
+	case "/api/v2/users/user_1/orders":
+		w.Write([]byte(`{"orders":[{"id":"ord_1","user_id":"user_1","status":"paid"},{"id":"ord_2","user_id":"user_1","status":"new"}]}`))
+	case "/api/v2/orders/ord_9":
+		w.Write([]byte(`{"id":"ord_9","user_id":"user_2","status":"paid"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newExecutor(t *testing.T, limits graphql.Limits) (*graphql.Executor, *upstream) {
+	t.Helper()
+	u := &upstream{calls: make(map[string]int)}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		UsersServiceURL:    srv.URL,
+		OrdersServiceURL:   srv.URL,
+		PaymentsServiceURL: srv.URL,
+		RequestTimeout:     5,
+	}
+	allowed := func(ctx context.Context, perm string) bool {
+		return strings.HasSuffix(perm, ":read")
+	}
+	return graphql.NewExecutor(proxy.NewClient(cfg), allowed, limits), u
+}
+
+func TestExecutor_Execute(t *testing.T) {
+	x, u := newExecutor(t, graphql.Limits{MaxDepth: 5, MaxComplexity: 200})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user_1")
+
+	resp := x.Execute(ctx, graphql.Request{
+		Query:     `query($other: ID!) { me { name orders { id user { id name } } } other: order(id: $other) { id } missing: order(id: "nope") { id } }`,
+		Variables: map[string]interface{}{"other": "ord_9"},
+	})
+	if resp.Data == nil {
+		t.Fatalf("expected data, got errors %+v", resp.Errors)
+	}
+
+	data, _ := json.Marshal(resp.Data)
+	want := `{"me":{"name":"Ada","orders":[{"id":"ord_1","user":{"id":"user_1","name":"Ada"}},{"id":"ord_2","user":{"id":"user_1","name":"Ada"}}]},"other":null,"missing":null}`
+	if string(data) != want {
+		t.Errorf("unexpected data:\n got %s\nwant %s", data, want)
+	}
+
+	// me and both orders' owners resolve to one users call.
+	if n := u.calls["/api/v2/users/user_1"]; n != 1 {
+		t.Errorf("expected 1 user lookup, got %d", n)
+	}
+
+	codes := map[string]string{}
+	for _, e := range resp.Errors {
+		codes[e.Path[0].(string)] = e.Extensions["code"]
+	}
+	// Another user's order is indistinguishable from a missing one.
+	if codes["other"] != graphql.CodeNotFound || codes["missing"] != graphql.CodeNotFound {
+		t.Errorf("expected NOT_FOUND for other and missing, got %v", codes)
+	}
+}
+
+func TestExecutor_Rejects(t *testing.T) {
+	x, u := newExecutor(t, graphql.Limits{MaxDepth: 3, MaxComplexity: 30})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user_1")
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"unknown field", `{ me { password } }`, graphql.CodeInvalidQuery},
+		{"missing argument", `{ order { id } }`, graphql.CodeInvalidQuery},
+		{"missing selection", `{ me }`, graphql.CodeInvalidQuery},
+		{"mutation", `mutation { me { id } }`, graphql.CodeInvalidQuery},
+		{"too deep", `{ me { orders { user { orders { id } } } } }`, graphql.CodeLimitExceeded},
+		{"too complex", `{ me { orders { id status total user { id } } } }`, graphql.CodeLimitExceeded},
+		{"lookup per list element", `{ orders { user { id } } }`, graphql.CodeLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := x.Execute(ctx, graphql.Request{Query: tt.query})
+			if resp.Data != nil {
+				t.Fatalf("expected request to be rejected")
+			}
+			if len(resp.Errors) == 0 || resp.Errors[0].Extensions["code"] != tt.code {
+				t.Errorf("expected %s, got %+v", tt.code, resp.Errors)
+			}
+		})
+	}
+
+	if len(u.calls) != 0 {
+		t.Errorf("expected no upstream calls for rejected queries, got %v", u.calls)
+	}
+}
+
+func TestExecutor_LimitsConcurrentFetches(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v2/users/user_1/orders":
+			orders := make([]string, 40)
+			for i := range orders {
+				orders[i] = fmt.Sprintf(`{"id":"ord_%d","user_id":"user_1","status":"paid"}`, i)
+			}
+			fmt.Fprintf(w, `{"orders":[%s]}`, strings.Join(orders, ","))
+		case "/api/v2/users/user_1":
+			w.Write([]byte(`{"id":"user_1","name":"Ada"}`))
+		default:
+			time.Sleep(5 * time.Millisecond)
+			w.Write([]byte(`{"payments":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{UsersServiceURL: srv.URL, OrdersServiceURL: srv.URL, PaymentsServiceURL: srv.URL, RequestTimeout: 5}
+	allowed := func(ctx context.Context, perm string) bool { return true }
+	x := graphql.NewExecutor(proxy.NewClient(cfg), allowed, graphql.Limits{})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user_1")
+
+	resp := x.Execute(ctx, graphql.Request{Query: `{ me { orders { id payments { id } } } }`})
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %+v", resp.Errors)
+	}
+	// One payments lookup per order, at most eight at a time.
+	if peak > 8 {
+		t.Errorf("expected at most 8 upstream calls in flight, got %d", peak)
+	}
+}
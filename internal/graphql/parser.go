@@ -0,0 +1,561 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operation is a parsed query operation.
+type Operation struct {
+	Type       string
+	Name       string
+	Variables  []*VariableDef
+	Selections []*Field
+}
+
+// VariableDef declares an operation variable.
+type VariableDef struct {
+	Name     string
+	Type     string
+	Required bool
+	Default  interface{}
+}
+
+// Field is a selected field. Arguments hold literal values, with Variable
+// standing in for $references until execution.
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  map[string]interface{}
+	Selections []*Field
+	Line       int
+	Column     int
+}
+
+// ResponseKey is the name the field's value is returned under.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Variable is a $name reference in an argument value.
+type Variable string
+
+// SyntaxError reports a malformed document.
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// DepthError reports a selection nested deeper than the parser allows.
+type DepthError struct {
+	Limit int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("query depth exceeds the limit of %d", e.Limit)
+}
+
+// Parse parses a document containing one or more operations. Fragments and
+// directives are not supported.
+func Parse(query string) ([]*Operation, error) {
+	return ParseWithDepth(query, 0)
+}
+
+// ParseWithDepth is Parse, but stops with a DepthError as soon as selections
+// nest deeper than maxDepth, so an oversized query is rejected before it is
+// fully parsed. Zero disables the limit.
+func ParseWithDepth(query string, maxDepth int) ([]*Operation, error) {
+	p := &parser{lex: lexer{src: query, line: 1, col: 1}, maxDepth: maxDepth}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var ops []*Operation
+	for p.tok.kind != tokEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, &SyntaxError{Message: "document has no operations", Line: 1, Column: 1}
+	}
+	return ops, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	line   int
+	column int
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+func (l *lexer) next() (token, error) {
+	// Whitespace, commas and comments are insignificant.
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.col = 1
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+			l.col++
+			continue
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	tok := token{line: l.line, column: l.col}
+	if l.pos >= len(l.src) {
+		tok.kind = tokEOF
+		return tok, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		l.col += 3
+		tok.kind, tok.value = tokPunct, "..."
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		l.pos++
+		l.col++
+		tok.kind, tok.value = tokPunct, string(c)
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		l.col += l.pos - start
+		tok.kind, tok.value = tokName, l.src[start:l.pos]
+	case c == '-' || isDigit(c):
+		start := l.pos
+		l.pos++
+		tok.kind = tokInt
+		for l.pos < len(l.src) {
+			d := l.src[l.pos]
+			if isDigit(d) {
+				l.pos++
+				continue
+			}
+			if d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && tok.kind == tokFloat) {
+				tok.kind = tokFloat
+				l.pos++
+				continue
+			}
+			break
+		}
+		l.col += l.pos - start
+		tok.value = l.src[start:l.pos]
+	case c == '"':
+		s, err := l.string()
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.value = tokString, s
+	default:
+		return tok, &SyntaxError{Message: fmt.Sprintf("unexpected character %q", c), Line: l.line, Column: l.col}
+	}
+	return tok, nil
+}
+
+func (l *lexer) string() (string, error) {
+	line, col := l.line, l.col
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		return "", &SyntaxError{Message: "block strings are not supported", Line: line, Column: col}
+	}
+
+	var b strings.Builder
+	l.pos++
+	l.col++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			l.col++
+			return b.String(), nil
+		case '\n':
+			return "", &SyntaxError{Message: "unterminated string", Line: line, Column: col}
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return "", &SyntaxError{Message: "unterminated string", Line: line, Column: col}
+			}
+			esc := l.src[l.pos+1]
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return "", &SyntaxError{Message: "invalid unicode escape", Line: l.line, Column: l.col}
+				}
+				r, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return "", &SyntaxError{Message: "invalid unicode escape", Line: l.line, Column: l.col}
+				}
+				b.WriteRune(rune(r))
+				l.pos += 4
+				l.col += 4
+			default:
+				return "", &SyntaxError{Message: fmt.Sprintf("invalid escape \\%c", esc), Line: l.line, Column: l.col}
+			}
+			l.pos += 2
+			l.col += 2
+		default:
+			b.WriteByte(c)
+			l.pos++
+			l.col++
+		}
+	}
+	return "", &SyntaxError{Message: "unterminated string", Line: line, Column: col}
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+
+type parser struct {
+	lex lexer
+	tok token
+
+	maxDepth int
+	depth    int
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Line: p.tok.line, Column: p.tok.column}
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.value == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q, found %s", punct, p.describe())
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected name, found %s", p.describe())
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return "end of document"
+	}
+	return strconv.Quote(p.tok.value)
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Type: "query"}
+
+	if p.tok.kind == tokName {
+		switch p.tok.value {
+		case "query", "mutation", "subscription":
+			op.Type = p.tok.value
+		case "fragment":
+			return nil, p.errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("unexpected %s", p.describe())
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName {
+			op.Name = p.tok.value
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("(") {
+			vars, err := p.parseVariableDefs()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = vars
+		}
+	}
+
+	sels, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = sels
+	return op, nil
+}
+
+func (p *parser) parseVariableDefs() ([]*VariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var defs []*VariableDef
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		def := &VariableDef{Name: name, Type: typ, Required: strings.HasSuffix(typ, "!")}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if def.Default, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, p.advance()
+}
+
+func (p *parser) parseType() (string, error) {
+	var typ string
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+		inner, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if p.peek("!") {
+		typ += "!"
+		return typ, p.advance()
+	}
+	return typ, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Field, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return nil, &DepthError{Limit: p.maxDepth}
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*Field
+	for !p.peek("}") {
+		if p.peek("...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("selection set is empty")
+	}
+	return fields, p.advance()
+}
+
+func (p *parser) parseField() (*Field, error) {
+	field := &Field{Line: p.tok.line, Column: p.tok.column}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		field.Arguments = make(map[string]interface{})
+		for !p.peek(")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if field.Arguments[arg], err = p.parseValue(false); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek("{") {
+		if field.Selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// parseValue parses an argument or default value. Variables are not
+// allowed in default values.
+func (p *parser) parseValue(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch {
+	case p.peek("$"):
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return Variable(name), err
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek("]") {
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		for !p.peek("}") {
+			key, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[key], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.advance()
+	case tok.kind == tokInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.value)
+		}
+		return float64(n), p.advance()
+	case tok.kind == tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.value)
+		}
+		return f, p.advance()
+	case tok.kind == tokString:
+		return tok.value, p.advance()
+	case tok.kind == tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = tok.value // enum value
+		}
+		return v, p.advance()
+	}
+	return nil, p.errorf("expected value, found %s", p.describe())
+}
@@ -0,0 +1,159 @@
+package graphql
+
+// typeDef is an object type. Objects of a type with a resource require
+// "<resource>:read", and "<resource>:any" when owner returns someone other
+// than the caller.
+type typeDef struct {
+	name     string
+	resource string
+	owner    string
+	fields   map[string]*fieldDef
+}
+
+// fieldDef describes a field. typ names an object type or a scalar (ID,
+// String, Int, Float, Boolean); scalar values are passed through from
+// upstream responses without coercion. Fields without a resolver read the
+// value of the same name from the parent object. A resolver calls an
+// upstream unless embedded is set, in which case it reads the parent.
+type fieldDef struct {
+	typ      string
+	list     bool
+	embedded bool
+	args     map[string]argDef
+	resolve  resolver
+}
+
+type argDef struct {
+	typ      string
+	required bool
+}
+
+type resolver func(e *execution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error)
+
+func scalar(typ string) *fieldDef {
+	return &fieldDef{typ: typ}
+}
+
+var idArg = map[string]argDef{"id": {typ: "ID", required: true}}
+
+// newSchema maps GraphQL types onto the REST endpoints the v2 handlers
+// proxy to.
+func newSchema() map[string]*typeDef {
+	types := map[string]*typeDef{
+		"Query": {
+			name: "Query",
+			fields: map[string]*fieldDef{
+				"me": {typ: "User", resolve: func(e *execution, _ map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					caller, err := e.caller()
+					if err != nil {
+						return nil, err
+					}
+					return e.load("users", "/api/v2/users/"+caller)
+				}},
+				"user": {typ: "User", args: idArg, resolve: func(e *execution, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					return e.load("users", "/api/v2/users/"+pathValue(args["id"]))
+				}},
+				"order": {typ: "Order", args: idArg, resolve: func(e *execution, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					return e.load("orders", "/api/v2/orders/"+pathValue(args["id"]))
+				}},
+				"orders": {typ: "Order", list: true, resolve: func(e *execution, _ map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					caller, err := e.caller()
+					if err != nil {
+						return nil, err
+					}
+					return e.loadList("orders", "/api/v2/users/"+caller+"/orders", "orders")
+				}},
+				"payment": {typ: "Payment", args: idArg, resolve: func(e *execution, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					return e.load("payments", "/api/v2/payments/"+pathValue(args["id"]))
+				}},
+				"notification": {typ: "Notification", args: idArg, resolve: func(e *execution, _ map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+					return e.load("notifications", "/api/v2/notifications/"+pathValue(args["id"]))
+				}},
+			},
+		},
+		"User": {
+			name:     "User",
+			resource: "users",
+			owner:    "id",
+			fields: map[string]*fieldDef{
+				"id":         scalar("ID"),
+				"email":      scalar("String"),
+				"name":       scalar("String"),
+				"role":       scalar("String"),
+				"created_at": scalar("String"),
+				"orders": {typ: "Order", list: true, resolve: func(e *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return e.loadList("orders", "/api/v2/users/"+pathValue(parent["id"])+"/orders", "orders")
+				}},
+			},
+		},
+		"Order": {
+			name:     "Order",
+			resource: "orders",
+			owner:    "user_id",
+			fields: map[string]*fieldDef{
+				"id":         scalar("ID"),
+				"user_id":    scalar("ID"),
+				"status":     scalar("String"),
+				"total":      scalar("Float"),
+				"currency":   scalar("String"),
+				"created_at": scalar("String"),
+				"items": {typ: "OrderItem", list: true, embedded: true, resolve: func(_ *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					items, _ := parent["items"].([]interface{})
+					return items, nil
+				}},
+				"user": {typ: "User", resolve: func(e *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return e.loadRef("users", "/api/v2/users/", parent["user_id"])
+				}},
+				"payments": {typ: "Payment", list: true, resolve: func(e *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return e.loadList("payments", "/api/v2/payments?order_id="+queryValue(parent["id"]), "payments")
+				}},
+			},
+		},
+		"OrderItem": {
+			name: "OrderItem",
+			fields: map[string]*fieldDef{
+				"product_id": scalar("ID"),
+				"name":       scalar("String"),
+				"quantity":   scalar("Int"),
+				"price":      scalar("Float"),
+			},
+		},
+		"Payment": {
+			name:     "Payment",
+			resource: "payments",
+			owner:    "user_id",
+			fields: map[string]*fieldDef{
+				"id":         scalar("ID"),
+				"order_id":   scalar("ID"),
+				"user_id":    scalar("ID"),
+				"amount":     scalar("Float"),
+				"currency":   scalar("String"),
+				"status":     scalar("String"),
+				"method":     scalar("String"),
+				"created_at": scalar("String"),
+				"order": {typ: "Order", resolve: func(e *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return e.loadRef("orders", "/api/v2/orders/", parent["order_id"])
+				}},
+				"user": {typ: "User", resolve: func(e *execution, parent map[string]interface{}, _ map[string]interface{}) (interface{}, error) {
+					return e.loadRef("users", "/api/v2/users/", parent["user_id"])
+				}},
+			},
+		},
+		"Notification": {
+			name:     "Notification",
+			resource: "notifications",
+			owner:    "user_id",
+			fields: map[string]*fieldDef{
+				"id":         scalar("ID"),
+				"user_id":    scalar("ID"),
+				"type":       scalar("String"),
+				"channel":    scalar("String"),
+				"status":     scalar("String"),
+				"subject":    scalar("String"),
+				"created_at": scalar("String"),
+			},
+		},
+	}
+
+	return types
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+)
+
+// AdminHandler serves operational endpoints on the admin listener.
+type AdminHandler struct {
+	config *config.Config
+}
+
+func NewAdminHandler(cfg *config.Config) *AdminHandler {
+	return &AdminHandler{config: cfg}
+}
+
+// Config dumps the effective configuration with secrets redacted.
+func (h *AdminHandler) Config(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.config.Redacted())
+}
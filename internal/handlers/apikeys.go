@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// APIKeysHandler serves the admin endpoints for managing API keys.
+type APIKeysHandler struct {
+	store *apikey.Store
+}
+
+func NewAPIKeysHandler(store *apikey.Store) *APIKeysHandler {
+	return &APIKeysHandler{store: store}
+}
+
+type CreateAPIKeyRequest struct {
+	Owner        string   `json:"owner"`
+	Role         string   `json:"role"`
+	Scopes       []string `json:"scopes"`
+	RateLimitRPS int      `json:"rate_limit_rps"`
+	ExpiresIn    string   `json:"expires_in"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string      `json:"key"`
+	APIKey *apikey.Key `json:"api_key"`
+}
+
+func (h *APIKeysHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Owner == "" {
+		http.Error(w, "Owner required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "Scopes required", http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid expires_in", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	key, raw, err := h.store.Create(apikey.CreateRequest{
+		Owner:        req.Owner,
+		Role:         req.Role,
+		Scopes:       req.Scopes,
+		RateLimitRPS: req.RateLimitRPS,
+		TTL:          ttl,
+	})
+	if err != nil {
+		logging.Error("Failed to create API key", logging.Fields{"error": err.Error()})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info("API key created", logging.Fields{
+		"api_key_id": key.ID,
+		"owner":      key.Owner,
+		"created_by": middleware.GetUserIDFromContext(r.Context()),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateAPIKeyResponse{Key: raw, APIKey: key})
+}
+
+func (h *APIKeysHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_keys": h.store.List(),
+	})
+}
+
+func (h *APIKeysHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("id")
+	if keyID == "" {
+		http.Error(w, "API key ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Revoke(keyID); err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		logging.Error("Failed to revoke API key", logging.Fields{"error": err.Error()})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info("API key revoked", logging.Fields{
+		"api_key_id": keyID,
+		"revoked_by": middleware.GetUserIDFromContext(r.Context()),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/audit"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 )
 
@@ -36,6 +39,19 @@ type RefreshRequest struct {
 	Token string `json:"token"`
 }
 
+type ImpersonateRequest struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+type ImpersonateResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"`
+	UserID    string `json:"user_id"`
+	ActorID   string `json:"actor_id"`
+	ReadOnly  bool   `json:"read_only"`
+}
+
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -83,6 +99,20 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Impersonation tokens are short-lived, read-only and audited at issue;
+	// a fresh one must come from /auth/impersonate.
+	if claims.Act != nil {
+		http.Error(w, "Impersonation tokens cannot be refreshed", http.StatusForbidden)
+		return
+	}
+
+	// Client tokens carry scopes and the OAuth TTL; clients request a new
+	// one from /oauth/token.
+	if claims.Scope != "" {
+		http.Error(w, "Scoped tokens cannot be refreshed", http.StatusForbidden)
+		return
+	}
+
 	newToken, err := h.jwtParser.Generate(claims.UserID, claims.Email, claims.Role)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -99,6 +129,62 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// USER-032: Token exchange for support staff
+// Impersonate mints a short-lived token for a target user carrying the
+// caller as the "act" claim. Impersonation tokens cannot be chained.
+func (h *AuthHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	var req ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" || req.Reason == "" {
+		http.Error(w, "user_id and reason required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if middleware.GetActorIDFromContext(ctx) != "" {
+		http.Error(w, "Cannot impersonate while impersonating", http.StatusForbidden)
+		return
+	}
+
+	actorID := middleware.GetUserIDFromContext(ctx)
+	if actorID == req.UserID {
+		http.Error(w, "Cannot impersonate yourself", http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(h.config.ImpersonationTTL) * time.Second
+	actor := jwt.Actor{Subject: actorID, Role: middleware.GetRoleFromContext(ctx)}
+
+	token, err := h.jwtParser.GenerateImpersonationToken(req.UserID, "customer", actor, ttl)
+	if err != nil {
+		logging.Error("Failed to generate token", logging.Fields{"error": err.Error()})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	audit.Log(ctx, audit.EventImpersonationStarted, logging.Fields{
+		"user_id":     req.UserID,
+		"actor_id":    actorID,
+		"actor_role":  actor.Role,
+		"reason":      req.Reason,
+		"ttl_seconds": int(ttl.Seconds()),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(ImpersonateResponse{
+		Token:     token,
+		ExpiresIn: int(ttl.Seconds()),
+		UserID:    req.UserID,
+		ActorID:   actorID,
+		ReadOnly:  h.config.ImpersonationReadOnly,
+	})
+}
+
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	logging.Info("User logged out")
 	w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// BatchPath is where the batch endpoint is served. Sub-requests may not
+// target it.
+const BatchPath = "/api/v2/batch"
+
+// maxBatchBodyBytes caps the size of a batch request body, as for GraphQL.
+const maxBatchBodyBytes = 1 << 20
+
+// Credential headers are always taken from the batch request itself, so an
+// item cannot act as anyone other than the batch's caller.
+var batchCredentialHeaders = []string{"Authorization", middleware.HeaderAPIKey, "X-Legacy-User-Id"}
+
+var batchMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true,
+}
+
+type BatchRequest struct {
+	Requests []BatchItem `json:"requests"`
+}
+
+// BatchItem is one sub-request. ID is echoed back to match responses.
+type BatchItem struct {
+	ID      string            `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResult is a sub-request's response. Body holds the response as JSON
+// when it parses, otherwise as a string.
+type BatchResult struct {
+	ID      string      `json:"id,omitempty"`
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    interface{} `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Responses []BatchResult `json:"responses"`
+}
+
+// USER-047: Batch endpoint for admin tooling
+// BatchHandler runs sub-requests through the gateway's router, so each one
+// is authenticated, authorized, rate limited and logged like a standalone
+// request.
+type BatchHandler struct {
+	router      http.Handler
+	maxItems    int
+	concurrency int
+}
+
+func NewBatchHandler(cfg *config.Config) *BatchHandler {
+	return &BatchHandler{
+		maxItems:    cfg.BatchMaxItems,
+		concurrency: max(cfg.BatchConcurrency, 1),
+	}
+}
+
+// SetRouter sets the handler sub-requests are dispatched to. It must be
+// called before the batch route serves traffic.
+func (h *BatchHandler) SetRouter(router http.Handler) {
+	h.router = router
+}
+
+func (h *BatchHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Batch body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) == 0 {
+		http.Error(w, "Batch has no requests", http.StatusBadRequest)
+		return
+	}
+	if h.maxItems > 0 && len(req.Requests) > h.maxItems {
+		http.Error(w, fmt.Sprintf("Batch exceeds %d requests", h.maxItems), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	logging.Info("Executing batch", logging.Fields{
+		"user_id":  middleware.GetUserIDFromContext(r.Context()),
+		"requests": len(req.Requests),
+	})
+
+	results := make([]BatchResult, len(req.Requests))
+	sem := make(chan struct{}, h.concurrency)
+	var wg sync.WaitGroup
+	for i, item := range req.Requests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, item BatchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = h.execute(r, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BatchResponse{Responses: results})
+}
+
+func (h *BatchHandler) execute(parent *http.Request, item BatchItem) BatchResult {
+	method := strings.ToUpper(item.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	invalid := func(msg string) BatchResult {
+		return BatchResult{ID: item.ID, Status: http.StatusBadRequest, Body: map[string]string{"error": msg}}
+	}
+	switch {
+	case !batchMethods[method]:
+		return invalid("unsupported method " + item.Method)
+	case !strings.HasPrefix(item.Path, "/") || strings.HasPrefix(item.Path, "//"):
+		return invalid("path must be absolute")
+	case strings.SplitN(item.Path, "?", 2)[0] == BatchPath:
+		return invalid("batches cannot be nested")
+	}
+
+	// Sub-requests start from a clean context so nothing the batch's own
+	// authentication stored leaks into them, but they are cancelled with
+	// the batch and share its deadline and request ID.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer context.AfterFunc(parent.Context(), cancel)()
+	if deadline, ok := parent.Context().Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	sub, err := http.NewRequestWithContext(ctx, method, item.Path, bytes.NewReader(item.Body))
+	if err != nil {
+		return invalid("invalid path")
+	}
+	sub.Host = parent.Host
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.TLS = parent.TLS
+	sub.Header.Set("User-Agent", parent.UserAgent())
+	if len(item.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range item.Headers {
+		sub.Header.Set(name, value)
+	}
+	if requestID := middleware.GetRequestIDFromContext(parent.Context()); requestID != "" {
+		sub.Header.Set(middleware.HeaderRequestID, requestID)
+	}
+	for _, name := range batchCredentialHeaders {
+		sub.Header.Del(name)
+		if value := parent.Header.Get(name); value != "" {
+			sub.Header.Set(name, value)
+		}
+	}
+
+	rec := &batchRecorder{header: make(http.Header)}
+	h.router.ServeHTTP(rec, sub)
+
+	result := BatchResult{ID: item.ID, Status: rec.status, Headers: rec.header}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if rec.body.Len() > 0 {
+		if json.Valid(rec.body.Bytes()) {
+			result.Body = json.RawMessage(rec.body.Bytes())
+		} else {
+			result.Body = rec.body.String()
+		}
+	}
+	return result
+}
+
+// batchRecorder captures a sub-request's response.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *batchRecorder) Header() http.Header { return b.header }
+
+func (b *batchRecorder) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *batchRecorder) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+)
+
+// BypassHandler reports the state of the legacy auth bypass registry.
+type BypassHandler struct {
+	registry *middleware.BypassRegistry
+}
+
+func NewBypassHandler(registry *middleware.BypassRegistry) *BypassHandler {
+	return &BypassHandler{registry: registry}
+}
+
+func (h *BypassHandler) ListBypasses(w http.ResponseWriter, r *http.Request) {
+	statuses := h.registry.Status()
+
+	active := 0
+	for _, s := range statuses {
+		if s.Active {
+			active++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"active_count": active,
+		"bypasses":     statuses,
+	})
+}
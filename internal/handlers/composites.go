@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/compose"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-045: Composite endpoints defined in COMPOSITES_PATH
+type CompositesHandler struct {
+	aggregator *compose.Aggregator
+	endpoints  []*compose.Endpoint
+}
+
+func NewCompositesHandler(proxy *proxy.Client, authMW *middleware.AuthMiddleware, endpoints []*compose.Endpoint) *CompositesHandler {
+	return &CompositesHandler{
+		aggregator: compose.NewAggregator(proxy, authMW.HasPermission),
+		endpoints:  endpoints,
+	}
+}
+
+// Endpoints returns the configured composite endpoints.
+func (h *CompositesHandler) Endpoints() []*compose.Endpoint {
+	return h.endpoints
+}
+
+// Serve returns the handler for ep. The rendered template is returned as-is,
+// with an "errors" object naming any optional calls that failed.
+func (h *CompositesHandler) Serve(ep *compose.Endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := compose.Vars{Path: make(map[string]string), Query: r.URL.Query()}
+		for _, name := range ep.Params() {
+			vars.Path[name] = r.PathValue(name)
+		}
+
+		result, err := h.aggregator.Execute(r.Context(), ep, vars)
+		if err != nil {
+			logging.Error("Composite endpoint failed", logging.Fields{
+				"route": ep.Pattern(),
+				"error": err.Error(),
+			})
+			writeCallError(w, err)
+			return
+		}
+
+		if len(result.Errors) > 0 {
+			logging.Warn("Composite response is partial", logging.Fields{
+				"route":  ep.Pattern(),
+				"errors": result.Errors,
+			})
+			result.Body["errors"] = result.Errors
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result.Body)
+	}
+}
+
+// writeCallError passes a failed upstream response through unchanged and
+// reports other failures with the failure's status.
+func writeCallError(w http.ResponseWriter, err error) {
+	callErr, ok := err.(*compose.CallError)
+	if !ok {
+		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if callErr.Err != nil {
+		writeProxyError(w, callErr.Err)
+		return
+	}
+	if callErr.Body != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(callErr.Failure.Status)
+		w.Write(callErr.Body)
+		return
+	}
+	http.Error(w, http.StatusText(callErr.Failure.Status), callErr.Failure.Status)
+}
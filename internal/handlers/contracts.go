@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+)
+
+// ContractsHandler reports upstream responses that drifted from their schemas.
+type ContractsHandler struct {
+	responses *middleware.ResponseValidationMiddleware
+}
+
+func NewContractsHandler(responses *middleware.ResponseValidationMiddleware) *ContractsHandler {
+	return &ContractsHandler{responses: responses}
+}
+
+func (h *ContractsHandler) Drift(w http.ResponseWriter, r *http.Request) {
+	var drift []middleware.ContractDrift
+	if h.responses != nil {
+		drift = h.responses.Drift()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routes": drift,
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/graphql"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// maxGraphQLBodyBytes caps the size of a GraphQL request body.
+const maxGraphQLBodyBytes = 1 << 20
+
+// USER-046: GraphQL endpoint
+type GraphQLHandler struct {
+	executor *graphql.Executor
+}
+
+func NewGraphQLHandler(proxy *proxy.Client, authMW *middleware.AuthMiddleware, cfg *config.Config) *GraphQLHandler {
+	return &GraphQLHandler{
+		executor: graphql.NewExecutor(proxy, authMW.HasPermission, graphql.Limits{
+			MaxDepth:      cfg.GraphQLMaxDepth,
+			MaxComplexity: cfg.GraphQLMaxComplexity,
+		}),
+	}
+}
+
+// Serve accepts queries as a JSON POST body or, for GET, in the query,
+// operationName and variables URL parameters. Requests rejected before
+// execution get a 400; field errors are reported alongside data with a 200.
+func (h *GraphQLHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp := h.executor.Execute(r.Context(), req)
+
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+		logging.Warn("GraphQL request rejected", logging.Fields{
+			"user_id": middleware.GetUserIDFromContext(r.Context()),
+			"errors":  resp.Errors,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,8 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/compose"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/oauth"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/translate"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
 )
 
 // PLAT-060: Standardized logging across all handlers (2024-03)
@@ -13,15 +23,63 @@ type Handlers struct {
 	Notifications *NotificationsHandler
 	Health        *HealthHandler
 	Auth          *AuthHandler
+	APIKeys       *APIKeysHandler
+	OAuth         *OAuthHandler
+	Bypass        *BypassHandler
+	Admin         *AdminHandler
+	Webhooks      *WebhooksHandler
+	Contracts     *ContractsHandler
+	Views         *ViewsHandler
+	Composites    *CompositesHandler
+	GraphQL       *GraphQLHandler
+	Batch         *BatchHandler
+	Translations  *TranslationsHandler
+	V1Usage       *V1UsageHandler
+}
+
+// Deps carries the stores and registries that handlers share with middleware.
+type Deps struct {
+	APIKeys      *apikey.Store
+	OAuthClients *oauth.Registry
+	Bypasses     *middleware.BypassRegistry
+	Auth         *middleware.AuthMiddleware
+	Webhooks     *webhookqueue.Queue
+	Responses    *middleware.ResponseValidationMiddleware
+	Composites   []*compose.Endpoint
+	Translations map[string]*translate.Rule
+	Validation   *middleware.ValidationMiddleware
+	V1Usage      *usage.Tracker
 }
 
-func NewHandlers(proxyClient *proxy.Client, cfg *config.Config) *Handlers {
+func NewHandlers(proxyClient *proxy.Client, cfg *config.Config, deps Deps) *Handlers {
 	return &Handlers{
 		Users:         NewUsersHandler(proxyClient),
 		Orders:        NewOrdersHandler(proxyClient),
-		Payments:      NewPaymentsHandler(proxyClient),
+		Payments:      NewPaymentsHandler(proxyClient, deps.Webhooks),
 		Notifications: NewNotificationsHandler(proxyClient),
 		Health:        NewHealthHandler(),
 		Auth:          NewAuthHandler(cfg),
+		APIKeys:       NewAPIKeysHandler(deps.APIKeys),
+		OAuth:         NewOAuthHandler(cfg, deps.OAuthClients),
+		Bypass:        NewBypassHandler(deps.Bypasses),
+		Admin:         NewAdminHandler(cfg),
+		Webhooks:      NewWebhooksHandler(deps.Webhooks),
+		Contracts:     NewContractsHandler(deps.Responses),
+		Views:         NewViewsHandler(proxyClient, deps.Auth),
+		Composites:    NewCompositesHandler(proxyClient, deps.Auth, deps.Composites),
+		GraphQL:       NewGraphQLHandler(proxyClient, deps.Auth, cfg),
+		Batch:         NewBatchHandler(cfg),
+		Translations:  NewTranslationsHandler(proxyClient, deps.Translations, deps.Validation),
+		V1Usage:       NewV1UsageHandler(deps.V1Usage),
+	}
+}
+
+// writeProxyError reports a failed upstream call: 504 when the request's
+// time budget ran out, 503 for any other failure.
+func writeProxyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, proxy.ErrDeadlineExceeded) {
+		http.Error(w, "Gateway timeout", http.StatusGatewayTimeout)
+		return
 	}
+	http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
 }
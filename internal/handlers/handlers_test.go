@@ -1,12 +1,27 @@
 package handlers_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/oauth"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/translate"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
 )
 
 func TestHealthHandler_Health(t *testing.T) {
@@ -53,6 +68,29 @@ func TestHealthHandler_Ready(t *testing.T) {
 	}
 }
 
+func TestHealthHandler_ReadyDraining(t *testing.T) {
+	h := handlers.NewHealthHandler()
+	h.SetDraining(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+	w := httptest.NewRecorder()
+
+	h.Ready(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+
+	var resp handlers.ReadinessResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Ready {
+		t.Error("expected ready to be false while draining")
+	}
+}
+
 func TestHealthHandler_Metrics(t *testing.T) {
 	h := handlers.NewHealthHandler()
 
@@ -74,3 +112,458 @@ func TestHealthHandler_Metrics(t *testing.T) {
 		t.Error("expected uptime_seconds in metrics")
 	}
 }
+
+func TestOAuthHandler_ClientCredentials(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret", OAuthTokenTTL: 3600, RateLimitRPS: 10}
+	clients, _ := oauth.LoadRegistry("")
+	clients.Register(&oauth.Client{
+		ID:         "billing-batch",
+		SecretHash: oauth.HashSecret("s3cret"),
+		Scopes:     []string{"orders:read", "payments:read"},
+	})
+	h := handlers.NewOAuthHandler(cfg, clients)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("billing-batch", "s3cret")
+	w := httptest.NewRecorder()
+
+	h.Token(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp handlers.TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if resp.Scope != "orders:read" {
+		t.Errorf("expected scope 'orders:read', got '%s'", resp.Scope)
+	}
+
+	authMW := middleware.NewAuthMiddleware(cfg, nil, nil)
+	protected := authMW.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := middleware.GetUserIDFromContext(r.Context()); userID != "billing-batch" {
+			t.Errorf("expected user ID 'billing-batch', got '%s'", userID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/orders/1", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.AccessToken)
+	w = httptest.NewRecorder()
+
+	protected.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestOAuthHandler_InvalidClient(t *testing.T) {
+	clients, _ := oauth.LoadRegistry("")
+	h := handlers.NewOAuthHandler(&config.Config{JWTSecret: "test-secret", OAuthTokenTTL: 3600}, clients)
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"nobody"}, "client_secret": {"x"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	h.Token(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestOAuthHandler_ClientWithoutScopes(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret", OAuthTokenTTL: 3600}
+	clients, _ := oauth.LoadRegistry("")
+	clients.Register(&oauth.Client{ID: "unscoped", SecretHash: oauth.HashSecret("s3cret")})
+	h := handlers.NewOAuthHandler(cfg, clients)
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("unscoped", "s3cret")
+	w := httptest.NewRecorder()
+
+	h.Token(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	path := filepath.Join(t.TempDir(), "clients.json")
+	os.WriteFile(path, []byte(`[{"id":"unscoped","secret_hash":"x"}]`), 0o600)
+	if _, err := oauth.LoadRegistry(path); err == nil {
+		t.Error("expected a client without scopes to be rejected")
+	}
+}
+
+func TestAuthHandler_RefreshScopedToken(t *testing.T) {
+	h := handlers.NewAuthHandler(&config.Config{JWTSecret: "test-secret"})
+	token, _ := jwt.NewParser("test-secret").GenerateClientToken("billing-batch", "service", "orders:read", time.Hour)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"token":"`+token+`"}`))
+	w := httptest.NewRecorder()
+	h.Refresh(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_Impersonate(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret", ImpersonationTTL: 900, ImpersonationReadOnly: true}
+	h := handlers.NewAuthHandler(cfg)
+	authMW := middleware.NewAuthMiddleware(cfg, nil, nil)
+
+	agentToken, _ := jwt.NewParser("test-secret").Generate("agent_1", "agent@example.com", "support")
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/impersonate", strings.NewReader(`{"user_id":"user_42","reason":"TICKET-9"}`))
+	req.Header.Set("Authorization", "Bearer "+agentToken)
+	w := httptest.NewRecorder()
+
+	authMW.Authenticate(http.HandlerFunc(h.Impersonate)).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp handlers.ImpersonateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	protected := authMW.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := middleware.GetUserIDFromContext(r.Context()); userID != "user_42" {
+			t.Errorf("expected user ID 'user_42', got '%s'", userID)
+		}
+		if actorID := middleware.GetActorIDFromContext(r.Context()); actorID != "agent_1" {
+			t.Errorf("expected actor ID 'agent_1', got '%s'", actorID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for read, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for write, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"token":"`+resp.Token+`"}`))
+	w = httptest.NewRecorder()
+	h.Refresh(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 when refreshing an impersonation token, got %d", w.Code)
+	}
+}
+
+func TestOrdersHandler_DeadlineExceeded(t *testing.T) {
+	deadlineHeaders := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadlineHeaders <- r.Header.Get(proxy.HeaderDeadline)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{OrdersServiceURL: upstream.URL, RequestTimeout: 30}
+	h := handlers.NewOrdersHandler(proxy.NewClient(cfg))
+
+	timeoutMW := middleware.NewTimeoutMiddleware(cfg)
+	handler := timeoutMW.Deadline(50 * time.Millisecond)(http.HandlerFunc(h.GetOrder))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/orders/ord_1", nil)
+	req.SetPathValue("id", "ord_1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+
+	deadlineHeader := <-deadlineHeaders
+	ms, err := strconv.Atoi(deadlineHeader)
+	if err != nil || ms <= 0 || ms > 50 {
+		t.Errorf("expected remaining deadline in (0, 50]ms, got %q", deadlineHeader)
+	}
+}
+
+func TestOwnershipMiddleware_DeadlineExceeded(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{OrdersServiceURL: upstream.URL, RequestTimeout: 30}
+	client := proxy.NewClient(cfg)
+	authMW := middleware.NewAuthMiddleware(&config.Config{JWTSecret: "test-secret"}, nil, nil)
+	ownerMW := middleware.NewOwnershipMiddleware(authMW, map[string]middleware.OwnerLookup{
+		"orders": client.OrderOwner,
+	}, time.Minute)
+
+	timeoutMW := middleware.NewTimeoutMiddleware(cfg)
+	handler := timeoutMW.Deadline(50 * time.Millisecond)(ownerMW.RequireOwner("orders")(http.HandlerFunc(handlers.NewOrdersHandler(client).GetOrder)))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/orders/ord_1", nil)
+	req.SetPathValue("id", "ord_1")
+	ctx := context.WithValue(req.Context(), middleware.ContextKeyUserID, "user_1")
+	ctx = context.WithValue(ctx, middleware.ContextKeyRole, "customer")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(ctx))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+}
+
+func TestPaymentsHandler_HandleWebhookQueued(t *testing.T) {
+	q, err := webhookqueue.Open(t.TempDir(), func(ctx context.Context, msg *webhookqueue.Message) error {
+		return nil
+	}, webhookqueue.Options{})
+	if err != nil {
+		t.Fatalf("failed to open queue: %v", err)
+	}
+
+	// The payments service is unreachable; the webhook must still be accepted.
+	cfg := &config.Config{PaymentsServiceURL: "http://127.0.0.1:1", RequestTimeout: 1}
+	h := handlers.NewPaymentsHandler(proxy.NewClient(cfg), q)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/payments/webhook", strings.NewReader(`{"id":"evt_1","type":"charge.succeeded"}`))
+	w := httptest.NewRecorder()
+	h.HandleWebhook(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", w.Code)
+	}
+	if q.Pending() != 1 {
+		t.Errorf("expected 1 pending webhook, got %d", q.Pending())
+	}
+}
+
+func TestViewsHandler_OrderView(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v2/orders/ord_1":
+			w.Write([]byte(`{"id":"ord_1","user_id":"user_1"}`))
+		case "/api/v2/users/user_1":
+			w.Write([]byte(`{"id":"user_1"}`))
+		case "/api/v2/payments":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		JWTSecret:          "test-secret",
+		OrdersServiceURL:   upstream.URL,
+		UsersServiceURL:    upstream.URL,
+		PaymentsServiceURL: upstream.URL,
+		RequestTimeout:     5,
+	}
+	authMW := middleware.NewAuthMiddleware(cfg, nil, nil)
+	h := handlers.NewViewsHandler(proxy.NewClient(cfg), authMW)
+	handler := authMW.Authenticate(http.HandlerFunc(h.OrderView))
+
+	token, _ := jwt.NewParser("test-secret").Generate("user_1", "user@example.com", "customer")
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/views/orders/ord_1", nil)
+	req.SetPathValue("id", "ord_1")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var view handlers.OrderView
+	if err := json.NewDecoder(w.Body).Decode(&view); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if string(view.Order) != `{"id":"ord_1","user_id":"user_1"}` {
+		t.Errorf("unexpected order section: %s", view.Order)
+	}
+	if string(view.User) != `{"id":"user_1"}` {
+		t.Errorf("unexpected user section: %s", view.User)
+	}
+	if string(view.Payments) != "null" {
+		t.Errorf("expected payments section to be null, got %s", view.Payments)
+	}
+	if e := view.Errors["payments"]; e.Error != "upstream_error" || e.Status != http.StatusInternalServerError {
+		t.Errorf("expected payments upstream_error marker, got %+v", e)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/views/orders/ord_2", nil)
+	req.SetPathValue("id", "ord_2")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing order, got %d", w.Code)
+	}
+}
+
+func TestBatchHandler_Serve(t *testing.T) {
+	h := handlers.NewBatchHandler(&config.Config{BatchMaxItems: 3, BatchConcurrency: 2})
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /api/v2/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetUserIDFromContext(r.Context()) != "" {
+			t.Error("expected sub-request context to carry no caller")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"id": r.PathValue("id"), "auth": r.Header.Get("Authorization")})
+	})
+	h.SetRouter(router)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, handlers.BatchPath, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer caller-token")
+		ctx := context.WithValue(req.Context(), middleware.ContextKeyUserID, "admin_1")
+		w := httptest.NewRecorder()
+		h.Serve(w, req.WithContext(ctx))
+		return w
+	}
+
+	w := serve(`{"requests":[
+		{"id":"a","method":"GET","path":"/api/v2/users/1","headers":{"Authorization":"Bearer other"}},
+		{"id":"b","method":"GET","path":"/api/v2/nowhere"},
+		{"id":"c","method":"POST","path":"/api/v2/batch"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		Responses []struct {
+			ID     string          `json:"id"`
+			Status int             `json:"status"`
+			Body   json.RawMessage `json:"body"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	want := []struct {
+		id     string
+		status int
+	}{{"a", http.StatusOK}, {"b", http.StatusNotFound}, {"c", http.StatusBadRequest}}
+	if len(resp.Responses) != len(want) {
+		t.Fatalf("expected %d responses, got %d", len(want), len(resp.Responses))
+	}
+	for i, wt := range want {
+		if got := resp.Responses[i]; got.ID != wt.id || got.Status != wt.status {
+			t.Errorf("response %d: expected %s/%d, got %s/%d", i, wt.id, wt.status, got.ID, got.Status)
+		}
+	}
+	if body := string(resp.Responses[0].Body); !strings.Contains(body, `"auth":"Bearer caller-token"`) {
+		t.Errorf("expected sub-request to use the batch's credentials, got %s", body)
+	}
+
+	w = serve(`{"requests":[{"path":"/a"},{"path":"/b"},{"path":"/c"},{"path":"/d"}]}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for an oversized batch, got %d", w.Code)
+	}
+
+	w = serve(`{"requests":[{"path":"/a","body":"` + strings.Repeat("x", 1<<20) + `"}]}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for an oversized body, got %d", w.Code)
+	}
+}
+
+func TestTranslationsHandler_For(t *testing.T) {
+	var gotBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/payments" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"pay_1","amount":19.99,"status":"pending"}`))
+	}))
+	defer upstream.Close()
+
+	rules, err := translate.Parse([]byte(`[{
+		"route": "POST /api/v1/payments",
+		"service": "payments",
+		"method": "POST",
+		"path": "/api/v2/payments",
+		"schema": "payment_create",
+		"request": {"keep_unmapped": true, "fields": [
+			{"from_caller": "user_id", "to": "user_id"},
+			{"from": "amount_cents", "to": "amount", "transform": "cents_to_decimal"}
+		]},
+		"response": {"keep_unmapped": true, "fields": [{"from": "amount", "to": "amount_cents", "transform": "decimal_to_cents"}]}
+	}]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := &config.Config{PaymentsServiceURL: upstream.URL, RequestTimeout: 5, SchemaValidationMode: middleware.ValidationEnforce}
+	schemas, err := schema.LoadDir("../../configs/schemas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := handlers.NewTranslationsHandler(proxy.NewClient(cfg), rules, middleware.NewValidationMiddleware(schemas, cfg))
+
+	legacy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	w := httptest.NewRecorder()
+	h.For("GET /api/v1/users/{id}", legacy).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/u1", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("expected untranslated route to use its legacy handler, got %d", w.Code)
+	}
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUserID, "user_1")
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/payments", strings.NewReader(`{"amount_cents":1999}`)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	h.For("POST /api/v1/payments", legacy).ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || gotBody != nil {
+		t.Fatalf("expected mapped body without order_id to fail its schema, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/payments", strings.NewReader(`{"order_id":"ord_1","amount_cents":1999,"user_id":"someone_else"}`)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	h.For("POST /api/v1/payments", legacy).ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	if gotBody["amount"] != 19.99 || gotBody["user_id"] != "user_1" {
+		t.Errorf("expected upstream amount 19.99 for user_1, got %v", gotBody)
+	}
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["amount_cents"] != float64(1999) || resp["status"] != "pending" {
+		t.Errorf("unexpected v1 response: %v", resp)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"runtime"
+	"sync/atomic"
 	"time"
 )
 
 type HealthHandler struct {
 	startTime time.Time
+	draining  atomic.Bool
 }
 
 func NewHealthHandler() *HealthHandler {
@@ -43,6 +45,12 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// SetDraining flips readiness so load balancers stop routing new traffic
+// before the listener closes.
+func (h *HealthHandler) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	resp := ReadinessResponse{
 		Ready: true,
@@ -51,7 +59,16 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	status := http.StatusOK
+	if h.draining.Load() {
+		resp.Ready = false
+		resp.Checks["gateway"] = false
+		resp.Details = map[string]string{"gateway": "draining"}
+		status = http.StatusServiceUnavailable
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
 
@@ -31,7 +31,7 @@ func (h *NotificationsHandler) SendNotification(w http.ResponseWriter, r *http.R
 	body, status, err := h.proxy.ProxyToNotifications(r.Context(), "POST", "/api/v2/notifications", req)
 	if err != nil {
 		logging.Error("Failed to send notification", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -49,7 +49,7 @@ func (h *NotificationsHandler) GetNotification(w http.ResponseWriter, r *http.Re
 
 	body, status, err := h.proxy.ProxyToNotifications(r.Context(), "GET", "/api/v2/notifications/"+notificationID, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -67,7 +67,7 @@ func (h *NotificationsHandler) SendEmail(w http.ResponseWriter, r *http.Request)
 
 	body, status, err := h.proxy.ProxyToNotifications(r.Context(), "POST", "/api/v2/notifications/email", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -85,7 +85,7 @@ func (h *NotificationsHandler) SendSMS(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToNotifications(r.Context(), "POST", "/api/v2/notifications/sms", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -108,12 +108,11 @@ func (h *NotificationsHandler) SendEmailLegacy(w http.ResponseWriter, r *http.Re
 
 	body, status, err := h.proxy.ProxyToNotifications(r.Context(), "POST", "/api/v1/email/send", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-API-Deprecated", "true")
 	w.WriteHeader(status)
 	w.Write(body)
 }
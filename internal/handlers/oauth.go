@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/oauth"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// OAuthHandler implements the OAuth2 token endpoint for machine clients.
+type OAuthHandler struct {
+	clients   *oauth.Registry
+	jwtParser *jwt.Parser
+	tokenTTL  time.Duration
+}
+
+func NewOAuthHandler(cfg *config.Config, clients *oauth.Registry) *OAuthHandler {
+	return &OAuthHandler{
+		clients:   clients,
+		jwtParser: jwt.NewParser(cfg.JWTSecret),
+		tokenTTL:  time.Duration(cfg.OAuthTokenTTL) * time.Second,
+	}
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// USER-027: OAuth2 client_credentials grant (RFC 6749 section 4.4)
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Malformed form body")
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if grantType != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only client_credentials is supported")
+		return
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	if clientID == "" || secret == "" {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication required")
+		return
+	}
+
+	client, err := h.clients.Authenticate(clientID, secret)
+	if err != nil {
+		logging.Warn("OAuth client authentication failed", logging.Fields{"client_id": clientID})
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	scopes, err := client.GrantScopes(strings.Fields(r.PostForm.Get("scope")))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	role := client.Role
+	if role == "" {
+		role = "service"
+	}
+	scope := strings.Join(scopes, " ")
+
+	token, err := h.jwtParser.GenerateClientToken(client.ID, role, scope, h.tokenTTL)
+	if err != nil {
+		logging.Error("Failed to generate token", logging.Fields{"error": err.Error()})
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	logging.Info("OAuth token issued", logging.Fields{
+		"client_id": client.ID,
+		"scope":     scope,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(h.tokenTTL.Seconds()),
+		Scope:       scope,
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="acme-shop-gateway"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(oauthError{Error: code, ErrorDescription: description})
+}
@@ -29,7 +29,7 @@ func (h *OrdersHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "GET", "/api/v2/orders/"+orderID, nil)
 	if err != nil {
 		logging.Error("Failed to get order", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -55,7 +55,7 @@ func (h *OrdersHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "POST", "/api/v2/orders", req)
 	if err != nil {
 		logging.Error("Failed to create order", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -79,7 +79,7 @@ func (h *OrdersHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request
 
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "PATCH", "/api/v2/orders/"+orderID+"/status", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -99,7 +99,7 @@ func (h *OrdersHandler) ListUserOrders(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "GET", path, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -117,7 +117,7 @@ func (h *OrdersHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "POST", "/api/v2/orders/"+orderID+"/cancel", nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -139,12 +139,11 @@ func (h *OrdersHandler) GetOrderV1(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToOrders(r.Context(), "GET", "/api/v1/orders/"+orderID, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-API-Deprecated", "true")
 	w.WriteHeader(status)
 	w.Write(body)
 }
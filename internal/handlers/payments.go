@@ -2,19 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 )
 
 type PaymentsHandler struct {
-	proxy *proxy.Client
+	proxy    *proxy.Client
+	webhooks *webhookqueue.Queue
 }
 
-func NewPaymentsHandler(proxy *proxy.Client) *PaymentsHandler {
-	return &PaymentsHandler{proxy: proxy}
+// NewPaymentsHandler creates the payments handler. With a nil webhook queue,
+// webhooks are forwarded to the payments service synchronously.
+func NewPaymentsHandler(proxy *proxy.Client, webhooks *webhookqueue.Queue) *PaymentsHandler {
+	return &PaymentsHandler{proxy: proxy, webhooks: webhooks}
 }
 
 func (h *PaymentsHandler) ProcessPayment(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +41,7 @@ func (h *PaymentsHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 	body, status, err := h.proxy.ProxyToPayments(r.Context(), "POST", "/api/v2/payments", req)
 	if err != nil {
 		logging.Error("Failed to process payment", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -54,7 +59,7 @@ func (h *PaymentsHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToPayments(r.Context(), "GET", "/api/v2/payments/"+paymentID, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -78,7 +83,7 @@ func (h *PaymentsHandler) RefundPayment(w http.ResponseWriter, r *http.Request)
 
 	body, status, err := h.proxy.ProxyToPayments(r.Context(), "POST", "/api/v2/payments/"+paymentID+"/refund", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -87,25 +92,45 @@ func (h *PaymentsHandler) RefundPayment(w http.ResponseWriter, r *http.Request)
 	w.Write(body)
 }
 
+// HandleWebhook accepts a provider event. The signature, timestamp and
+// event ID have already been checked by middleware.WebhookVerifier.
 func (h *PaymentsHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	var payload map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	signature := r.Header.Get("X-Stripe-Signature")
-	if signature == "" {
-		signature = r.Header.Get("X-Webhook-Signature")
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
 	logging.Info("Received payment webhook", logging.Fields{
+		"event_id":   payload["id"],
 		"event_type": payload["type"],
 	})
 
+	// USER-040: Persist and acknowledge; the queue delivers with retries.
+	if h.webhooks != nil {
+		eventID, _ := payload["id"].(string)
+		msg, err := h.webhooks.Enqueue(eventID, raw)
+		if err != nil {
+			logging.Error("Failed to queue webhook", logging.Fields{"error": err.Error()})
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "queued", "id": msg.ID})
+		return
+	}
+
 	body, status, err := h.proxy.ProxyToPayments(r.Context(), "POST", "/api/v2/payments/webhook", payload)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -127,12 +152,11 @@ func (h *PaymentsHandler) ProcessPaymentV1(w http.ResponseWriter, r *http.Reques
 
 	body, status, err := h.proxy.ProxyToPayments(r.Context(), "POST", "/api/v1/payments", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-API-Deprecated", "true")
 	w.WriteHeader(status)
 	w.Write(body)
 }
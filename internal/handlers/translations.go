@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/translate"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-048: v1 routes translated onto v2 upstream endpoints. Mapped request
+// bodies are checked against the rule's schema with the same validation the
+// v2 routes use.
+type TranslationsHandler struct {
+	proxy      *proxy.Client
+	rules      map[string]*translate.Rule
+	validation *middleware.ValidationMiddleware
+}
+
+func NewTranslationsHandler(proxy *proxy.Client, rules map[string]*translate.Rule, validation *middleware.ValidationMiddleware) *TranslationsHandler {
+	return &TranslationsHandler{proxy: proxy, rules: rules, validation: validation}
+}
+
+// For returns the translating handler for the v1 route pattern, or legacy
+// when no rule covers it.
+func (h *TranslationsHandler) For(pattern string, legacy http.HandlerFunc) http.HandlerFunc {
+	rule := h.rules[pattern]
+	if rule == nil {
+		return legacy
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, rule)
+	}
+}
+
+func (h *TranslationsHandler) serve(w http.ResponseWriter, r *http.Request, rule *translate.Rule) {
+	caller := translate.Caller{
+		UserID: middleware.GetUserIDFromContext(r.Context()),
+		Role:   middleware.GetRoleFromContext(r.Context()),
+	}
+
+	var reqBody interface{}
+	if r.Body != nil {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			var v1 interface{}
+			if err := json.Unmarshal(data, &v1); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if reqBody, err = rule.Request.Apply(v1, caller); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	call := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.call(w, r, rule, caller, reqBody)
+	})
+	if rule.Schema == "" || h.validation == nil {
+		call(w, r)
+		return
+	}
+
+	mapped, err := json.Marshal(reqBody)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(mapped))
+	r.ContentLength = int64(len(mapped))
+	h.validation.Validate(rule.Schema)(call).ServeHTTP(w, r)
+}
+
+// call sends the mapped request upstream and maps the response back.
+func (h *TranslationsHandler) call(w http.ResponseWriter, r *http.Request, rule *translate.Rule, caller translate.Caller, reqBody interface{}) {
+
+	path := rule.UpstreamPath(r)
+	logging.Warn("V1 API translated", logging.Fields{
+		"route":    rule.Route,
+		"upstream": rule.Method + " " + path,
+	})
+
+	body, status, err := h.upstream(rule.Service)(r.Context(), rule.Method, path, reqBody)
+	if err != nil {
+		logging.Error("Translated v1 call failed", logging.Fields{
+			"route": rule.Route,
+			"error": err.Error(),
+		})
+		writeProxyError(w, err)
+		return
+	}
+
+	// Only successful responses are reshaped; upstream errors pass through.
+	if status >= 200 && status < 300 && len(bytes.TrimSpace(body)) > 0 {
+		var v2 interface{}
+		if err := json.Unmarshal(body, &v2); err != nil {
+			logging.Error("Translated v1 response is not JSON", logging.Fields{"route": rule.Route})
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+			return
+		}
+		v1, err := rule.Response.Apply(v2, caller)
+		if err != nil {
+			logging.Error("Failed to translate v1 response", logging.Fields{
+				"route": rule.Route,
+				"error": err.Error(),
+			})
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+			return
+		}
+		if body, err = json.Marshal(v1); err != nil {
+			http.Error(w, "Bad gateway", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (h *TranslationsHandler) upstream(service string) proxyFunc {
+	switch service {
+	case "users":
+		return h.proxy.ProxyToUsers
+	case "orders":
+		return h.proxy.ProxyToOrders
+	case "payments":
+		return h.proxy.ProxyToPayments
+	default:
+		return h.proxy.ProxyToNotifications
+	}
+}
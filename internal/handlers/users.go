@@ -28,7 +28,7 @@ func (h *UsersHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	body, status, err := h.proxy.ProxyToUsers(r.Context(), "GET", "/api/v2/users/"+userID, nil)
 	if err != nil {
 		logging.Error("Failed to get user", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -49,7 +49,7 @@ func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	body, status, err := h.proxy.ProxyToUsers(r.Context(), "POST", "/api/v2/users", req)
 	if err != nil {
 		logging.Error("Failed to create user", logging.Fields{"error": err.Error()})
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -73,7 +73,7 @@ func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToUsers(r.Context(), "PUT", "/api/v2/users/"+userID, req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -91,7 +91,7 @@ func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToUsers(r.Context(), "DELETE", "/api/v2/users/"+userID, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -108,7 +108,7 @@ func (h *UsersHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToUsers(r.Context(), "GET", path, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
@@ -131,13 +131,11 @@ func (h *UsersHandler) GetUserV1(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToUsersLegacy(r.Context(), "GET", "/users/"+userID, nil)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-API-Deprecated", "true")
-	w.Header().Set("X-API-Sunset", "2024-06-01")
 	w.WriteHeader(status)
 	w.Write(body)
 }
@@ -155,12 +153,11 @@ func (h *UsersHandler) CreateUserV1(w http.ResponseWriter, r *http.Request) {
 
 	body, status, err := h.proxy.ProxyToUsersLegacy(r.Context(), "POST", "/users", req)
 	if err != nil {
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+		writeProxyError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-API-Deprecated", "true")
 	w.WriteHeader(status)
 	w.Write(body)
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-050: V1UsageHandler reports who still calls deprecated v1 routes.
+type V1UsageHandler struct {
+	tracker *usage.Tracker
+}
+
+func NewV1UsageHandler(tracker *usage.Tracker) *V1UsageHandler {
+	return &V1UsageHandler{tracker: tracker}
+}
+
+func (h *V1UsageHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"usage": h.records(),
+	})
+}
+
+func (h *V1UsageHandler) Export(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="v1_usage.csv"`)
+	if err := usage.WriteCSV(w, h.records()); err != nil {
+		logging.Error("Failed to export v1 usage", logging.Fields{"error": err.Error()})
+	}
+}
+
+func (h *V1UsageHandler) records() []usage.Record {
+	if h.tracker == nil {
+		return []usage.Record{}
+	}
+	return h.tracker.Snapshot()
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/proxy"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// SectionError marks a part of a composite view that could not be filled.
+type SectionError struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// OrderView is the order detail screen in one document. Sections that
+// failed are null and explained in Errors.
+type OrderView struct {
+	Order    json.RawMessage         `json:"order"`
+	Payments json.RawMessage         `json:"payments"`
+	User     json.RawMessage         `json:"user"`
+	Errors   map[string]SectionError `json:"errors,omitempty"`
+}
+
+// USER-044: Backend-for-frontend views
+// ViewsHandler serves composite documents assembled from several upstreams.
+type ViewsHandler struct {
+	proxy  *proxy.Client
+	authMW *middleware.AuthMiddleware
+}
+
+func NewViewsHandler(proxy *proxy.Client, authMW *middleware.AuthMiddleware) *ViewsHandler {
+	return &ViewsHandler{proxy: proxy, authMW: authMW}
+}
+
+// section is the outcome of one upstream call.
+type section struct {
+	body   []byte
+	status int
+	err    error
+}
+
+type proxyFunc func(ctx context.Context, method, path string, body interface{}) ([]byte, int, error)
+
+func fetch(ctx context.Context, call proxyFunc, path string) section {
+	body, status, err := call(ctx, "GET", path, nil)
+	return section{body: body, status: status, err: err}
+}
+
+// sectionError describes a failed call, or returns nil for a 2xx response.
+func (s section) sectionError() *SectionError {
+	switch {
+	case errors.Is(s.err, proxy.ErrDeadlineExceeded):
+		return &SectionError{Error: "timeout", Status: http.StatusGatewayTimeout}
+	case s.err != nil:
+		return &SectionError{Error: "unavailable", Status: http.StatusServiceUnavailable}
+	case s.status == http.StatusNotFound:
+		return &SectionError{Error: "not_found", Status: s.status}
+	case s.status < 200 || s.status >= 300:
+		return &SectionError{Error: "upstream_error", Status: s.status}
+	}
+	return nil
+}
+
+// OrderView returns an order with its payments and owner. The order and its
+// payments are fetched concurrently; the owner is fetched as soon as the
+// order names them. Only a failed order fails the request; other sections
+// degrade to an entry in Errors.
+func (h *ViewsHandler) OrderView(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("id")
+	if orderID == "" {
+		http.Error(w, "Order ID required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var payments section
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		payments = fetch(ctx, h.proxy.ProxyToPayments, "/api/v2/payments?order_id="+url.QueryEscape(orderID))
+	}()
+
+	order := fetch(ctx, h.proxy.ProxyToOrders, "/api/v2/orders/"+url.PathEscape(orderID))
+	if order.err != nil {
+		cancel()
+		wg.Wait()
+		logging.Error("Failed to get order for view", logging.Fields{"order_id": orderID, "error": order.err.Error()})
+		writeProxyError(w, order.err)
+		return
+	}
+	if order.status != http.StatusOK {
+		cancel()
+		wg.Wait()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(order.status)
+		w.Write(order.body)
+		return
+	}
+
+	var owner struct {
+		UserID string `json:"user_id"`
+	}
+	json.Unmarshal(order.body, &owner)
+
+	view := OrderView{Order: order.body, Errors: make(map[string]SectionError)}
+
+	switch {
+	case owner.UserID == "":
+		view.Errors["user"] = SectionError{Error: "unavailable", Status: http.StatusBadGateway}
+	case !h.allowed(ctx, "users", owner.UserID):
+		view.Errors["user"] = SectionError{Error: "forbidden", Status: http.StatusForbidden}
+	default:
+		user := fetch(ctx, h.proxy.ProxyToUsers, "/api/v2/users/"+url.PathEscape(owner.UserID))
+		if e := user.sectionError(); e != nil {
+			view.Errors["user"] = *e
+		} else {
+			view.User = user.body
+		}
+	}
+
+	wg.Wait()
+	if !h.allowed(ctx, "payments", owner.UserID) {
+		view.Errors["payments"] = SectionError{Error: "forbidden", Status: http.StatusForbidden}
+	} else if e := payments.sectionError(); e != nil {
+		view.Errors["payments"] = *e
+	} else {
+		view.Payments = payments.body
+	}
+
+	if len(view.Errors) > 0 {
+		logging.Warn("Order view is partial", logging.Fields{
+			"order_id": orderID,
+			"errors":   view.Errors,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// allowed applies the same rules as the standalone routes: the caller needs
+// "<resource>:read", and "<resource>:any" for data belonging to someone else.
+func (h *ViewsHandler) allowed(ctx context.Context, resource, ownerID string) bool {
+	if !h.authMW.HasPermission(ctx, resource+":read") {
+		return false
+	}
+	userID := middleware.GetUserIDFromContext(ctx)
+	return (ownerID != "" && ownerID == userID) || h.authMW.HasPermission(ctx, resource+":any")
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// WebhooksHandler serves the admin endpoints for the webhook dead-letter store.
+type WebhooksHandler struct {
+	queue *webhookqueue.Queue
+}
+
+func NewWebhooksHandler(queue *webhookqueue.Queue) *WebhooksHandler {
+	return &WebhooksHandler{queue: queue}
+}
+
+func (h *WebhooksHandler) ListDead(w http.ResponseWriter, r *http.Request) {
+	if h.queue == nil {
+		http.Error(w, "Webhook queue disabled", http.StatusNotFound)
+		return
+	}
+
+	dead := h.queue.Dead()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pending_count": h.queue.Pending(),
+		"dead_count":    len(dead),
+		"dead":          dead,
+	})
+}
+
+func (h *WebhooksHandler) ReplayDead(w http.ResponseWriter, r *http.Request) {
+	if h.queue == nil {
+		http.Error(w, "Webhook queue disabled", http.StatusNotFound)
+		return
+	}
+
+	msg, err := h.queue.Replay(r.PathValue("id"))
+	if errors.Is(err, webhookqueue.ErrNotFound) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Error("Failed to replay webhook", logging.Fields{"error": err.Error()})
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info("Webhook replayed", logging.Fields{
+		"webhook_id":  msg.ID,
+		"event_id":    msg.EventID,
+		"replayed_by": middleware.GetUserIDFromContext(r.Context()),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(msg)
+}
@@ -11,9 +11,17 @@ type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	Scope  string `json:"scope,omitempty"`
+	Act    *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Actor identifies the party acting on behalf of the subject (RFC 8693 "act" claim).
+type Actor struct {
+	Subject string `json:"sub"`
+	Role    string `json:"role,omitempty"`
+}
+
 type Parser struct {
 	secret []byte
 }
@@ -60,6 +68,46 @@ func (p *Parser) Generate(userID, email, role string) (string, error) {
 	return token.SignedString(p.secret)
 }
 
+// GenerateClientToken issues a token for a machine client. The client ID is
+// used as both subject and user ID, and scope is a space-delimited list.
+func (p *Parser) GenerateClientToken(clientID, role, scope string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: clientID,
+		Role:   role,
+		Scope:  scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   clientID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "acme-shop-gateway",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(p.secret)
+}
+
+// GenerateImpersonationToken issues a short-lived token for userID that
+// records actor in the "act" claim.
+func (p *Parser) GenerateImpersonationToken(userID, role string, actor Actor, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: userID,
+		Role:   role,
+		Act:    &actor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "acme-shop-gateway",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(p.secret)
+}
+
 // ParseLegacy parses tokens using the old format.
 // Deprecated: Use Parse instead.
 // TODO(TEAM-SEC): Remove after all services use new token format
@@ -2,6 +2,7 @@ package jwt_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
 )
@@ -59,3 +60,25 @@ func TestParser_ParseWrongSecret(t *testing.T) {
 		t.Error("expected error when parsing with wrong secret")
 	}
 }
+
+func TestParser_GenerateClientToken(t *testing.T) {
+	parser := jwt.NewParser("test-secret")
+
+	token, err := parser.GenerateClientToken("billing-batch", "service", "orders:read payments:read", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	claims, err := parser.Parse(token)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+
+	if claims.Subject != "billing-batch" {
+		t.Errorf("expected subject 'billing-batch', got '%s'", claims.Subject)
+	}
+
+	if claims.Scope != "orders:read payments:read" {
+		t.Errorf("expected scope 'orders:read payments:read', got '%s'", claims.Scope)
+	}
+}
@@ -2,35 +2,79 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/audit"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/policy"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 )
 
 type contextKey string
 
 const (
-	ContextKeyUserID contextKey = "user_id"
-	ContextKeyRole   contextKey = "role"
+	ContextKeyUserID   contextKey = "user_id"
+	ContextKeyRole     contextKey = "role"
+	ContextKeyAPIKeyID contextKey = "api_key_id"
+	ContextKeyScopes   contextKey = "scopes"
+	ContextKeyActorID  contextKey = "actor_id"
+	ContextKeyLegacy   contextKey = "legacy_auth"
 )
 
+// HeaderAPIKey carries an API key for service and partner clients.
+const HeaderAPIKey = "X-API-Key"
+
 type AuthMiddleware struct {
 	config    *config.Config
 	jwtParser *jwt.Parser
+	apiKeys   *apikey.Store
+	keyLimits *RateLimitMiddleware
+	policy    *policy.Policy
+
+	legacyNets []*net.IPNet
 }
 
-func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
+func NewAuthMiddleware(cfg *config.Config, apiKeys *apikey.Store, pol *policy.Policy) *AuthMiddleware {
+	if pol == nil {
+		pol = policy.Default()
+	}
+
+	var legacyNets []*net.IPNet
+	for _, cidr := range cfg.LegacyAuthAllowedCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logging.Warnf("Ignoring invalid legacy auth CIDR %q: %v", cidr, err)
+			continue
+		}
+		legacyNets = append(legacyNets, n)
+	}
+
 	return &AuthMiddleware{
-		config:    cfg,
-		jwtParser: jwt.NewParser(cfg.JWTSecret),
+		config:     cfg,
+		jwtParser:  jwt.NewParser(cfg.JWTSecret),
+		apiKeys:    apiKeys,
+		keyLimits:  NewRateLimitMiddleware(cfg),
+		policy:     pol,
+		legacyNets: legacyNets,
 	}
 }
 
+// Stop releases the per-key rate limiter's background goroutine.
+func (m *AuthMiddleware) Stop() {
+	m.keyLimits.Stop()
+}
+
 func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rawKey := apiKeyFromRequest(r); rawKey != "" {
+			m.authenticateAPIKey(w, r, rawKey, next)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
@@ -52,19 +96,115 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		ctx := context.WithValue(r.Context(), ContextKeyUserID, claims.UserID)
 		ctx = context.WithValue(ctx, ContextKeyRole, claims.Role)
+		if claims.Scope != "" {
+			ctx = context.WithValue(ctx, ContextKeyScopes, strings.Fields(claims.Scope))
+		}
+
+		if claims.Act != nil {
+			ctx = context.WithValue(ctx, ContextKeyActorID, claims.Act.Subject)
+			if !m.allowImpersonated(w, r.WithContext(ctx), claims) {
+				return
+			}
+		}
 
 		logging.Info("Request authenticated", logging.Fields{
-			"user_id": claims.UserID,
-			"role":    claims.Role,
+			"user_id":  claims.UserID,
+			"role":     claims.Role,
+			"actor_id": GetActorIDFromContext(ctx),
 		})
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// USER-032: Support impersonation tokens carry an "act" claim. Every request
+// made with one is audited, and writes are refused when configured read-only.
+func (m *AuthMiddleware) allowImpersonated(w http.ResponseWriter, r *http.Request, claims *jwt.Claims) bool {
+	fields := logging.Fields{
+		"user_id":  claims.UserID,
+		"actor_id": claims.Act.Subject,
+		"method":   r.Method,
+		"path":     r.URL.Path,
+	}
+
+	if m.config.ImpersonationReadOnly && !isReadOnlyMethod(r.Method) {
+		audit.Log(r.Context(), audit.EventImpersonationWriteBlocked, fields)
+		http.Error(w, "Write operations are not allowed while impersonating", http.StatusForbidden)
+		return false
+	}
+
+	audit.Log(r.Context(), audit.EventImpersonatedRequest, fields)
+	return true
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// USER-026: API key authentication for service and partner clients
+func (m *AuthMiddleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, rawKey string, next http.Handler) {
+	if m.apiKeys == nil {
+		http.Error(w, "API key authentication disabled", http.StatusUnauthorized)
+		return
+	}
+
+	key, err := m.apiKeys.Verify(rawKey)
+	if err != nil {
+		logging.Warn("API key rejected", logging.Fields{
+			"error": err.Error(),
+			"path":  r.URL.Path,
+		})
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	if key.RateLimitRPS > 0 && !m.keyLimits.AllowKey("apikey:"+key.ID, key.RateLimitRPS) {
+		logging.Warn("API key rate limit exceeded", logging.Fields{
+			"api_key_id": key.ID,
+			"owner":      key.Owner,
+		})
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	role := key.Role
+	if role == "" {
+		role = "service"
+	}
+
+	ctx := context.WithValue(r.Context(), ContextKeyUserID, key.Owner)
+	ctx = context.WithValue(ctx, ContextKeyRole, role)
+	ctx = context.WithValue(ctx, ContextKeyAPIKeyID, key.ID)
+	// A key without scopes is denied everything, as is an OAuth client.
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	ctx = context.WithValue(ctx, ContextKeyScopes, scopes)
+
+	logging.Info("Request authenticated", logging.Fields{
+		"user_id":    key.Owner,
+		"role":       role,
+		"api_key_id": key.ID,
+	})
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// apiKeyFromRequest reads a key from X-API-Key or an "ApiKey" Authorization header.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return key
+	}
+	return ""
+}
+
 // API-175: DEPRECATED - Legacy authentication middleware
 // AuthenticateLegacy uses the old X-Legacy-User-Id header for authentication.
-// Deprecated: Use Authenticate with JWT tokens instead.
+// Deprecated: Use Authenticate with JWT tokens, or AuthenticateHybrid while migrating v1 routes.
 // TODO(TEAM-SEC): Remove after migration to JWT auth is complete
 func (m *AuthMiddleware) AuthenticateLegacy(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -105,6 +245,38 @@ func (m *AuthMiddleware) RequireRole(roles ...string) func(http.Handler) http.Ha
 	}
 }
 
+// USER-028: Permission-based authorization
+// RequirePermission allows the request when the caller's role grants perm
+// under the policy and, for scope-restricted credentials, a scope covers it.
+func (m *AuthMiddleware) RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !m.HasPermission(r.Context(), perm) {
+				logging.Warn("Authorization denied", logging.Fields{
+					"user_id":    GetUserIDFromContext(r.Context()),
+					"role":       GetRoleFromContext(r.Context()),
+					"permission": perm,
+					"method":     r.Method,
+					"path":       r.URL.Path,
+				})
+				http.Error(w, "Missing permission: "+perm, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasPermission reports whether the authenticated caller in ctx holds perm.
+func (m *AuthMiddleware) HasPermission(ctx context.Context, perm string) bool {
+	role := GetRoleFromContext(ctx)
+	if role == "" {
+		return false
+	}
+	return m.policy.Allowed(role, GetScopesFromContext(ctx), perm)
+}
+
 // GetUserIDFromContext retrieves the user ID from the request context.
 func GetUserIDFromContext(ctx context.Context) string {
 	if userID, ok := ctx.Value(ContextKeyUserID).(string); ok {
@@ -120,3 +292,36 @@ func GetRoleFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// GetAPIKeyIDFromContext retrieves the API key ID from the request context.
+func GetAPIKeyIDFromContext(ctx context.Context) string {
+	if keyID, ok := ctx.Value(ContextKeyAPIKeyID).(string); ok {
+		return keyID
+	}
+	return ""
+}
+
+// GetScopesFromContext retrieves the credential scopes from the request context.
+// A nil result means the credential is not scope-restricted.
+func GetScopesFromContext(ctx context.Context) []string {
+	if scopes, ok := ctx.Value(ContextKeyScopes).([]string); ok {
+		return scopes
+	}
+	return nil
+}
+
+// GetActorIDFromContext retrieves the impersonating actor's ID from the
+// request context, or "" when the caller is not being impersonated.
+func GetActorIDFromContext(ctx context.Context) string {
+	if actorID, ok := ctx.Value(ContextKeyActorID).(string); ok {
+		return actorID
+	}
+	return ""
+}
+
+// GetLegacyAuthFromContext reports whether the caller was identified by the
+// X-Legacy-User-Id fallback rather than a JWT or API key.
+func GetLegacyAuthFromContext(ctx context.Context) bool {
+	legacy, _ := ctx.Value(ContextKeyLegacy).(bool)
+	return legacy
+}
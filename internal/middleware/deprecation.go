@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Deprecation is a route's deprecation metadata. Since is when the route
+// was deprecated and Sunset when it stops being served; either may be
+// zero if not yet announced. Link points to migration documentation.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	Link   string
+}
+
+// ParseDate accepts a calendar date ("2024-06-01", midnight UTC) or an
+// RFC 3339 timestamp. An empty string yields the zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+type brownout struct {
+	start, end time.Time
+}
+
+// USER-049: RFC 8594 Sunset and Deprecation headers for deprecated routes.
+// With SUNSET_ENFORCE set, routes past their sunset date answer 410 Gone,
+// as do routes with a sunset date during a SUNSET_BROWNOUTS window.
+type DeprecationMiddleware struct {
+	enforce   bool
+	brownouts []brownout
+	now       func() time.Time
+}
+
+// NewDeprecationMiddleware reads SUNSET_BROWNOUTS entries of the form
+// "<start>/<end>", each an RFC 3339 timestamp.
+func NewDeprecationMiddleware(cfg *config.Config) *DeprecationMiddleware {
+	m := &DeprecationMiddleware{enforce: cfg.SunsetEnforce, now: time.Now}
+	for _, date := range []string{cfg.V1DeprecationDate, cfg.V1SunsetDate} {
+		if _, err := ParseDate(date); err != nil {
+			logging.Warnf("Ignoring invalid v1 deprecation date %q", date)
+		}
+	}
+	for _, entry := range cfg.SunsetBrownouts {
+		startValue, endValue, _ := strings.Cut(entry, "/")
+		start, err1 := time.Parse(time.RFC3339, strings.TrimSpace(startValue))
+		end, err2 := time.Parse(time.RFC3339, strings.TrimSpace(endValue))
+		if err1 != nil || err2 != nil || !end.After(start) {
+			logging.Warnf("Ignoring invalid sunset brownout %q", entry)
+			continue
+		}
+		m.brownouts = append(m.brownouts, brownout{start: start, end: end})
+	}
+	return m
+}
+
+// Signal adds deprecation headers for the route and, when enforcing,
+// rejects requests once it is sunset or during a brownout.
+func (m *DeprecationMiddleware) Signal(pattern string, d *Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			if d.Since.IsZero() {
+				header.Set("Deprecation", "true")
+			} else {
+				header.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			}
+			if !d.Sunset.IsZero() {
+				header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Link != "" {
+				rel := "deprecation"
+				if !d.Sunset.IsZero() {
+					rel += " sunset"
+				}
+				header.Add("Link", fmt.Sprintf(`<%s>; rel="%s"; type="text/html"`, d.Link, rel))
+			}
+
+			if !m.enforce || d.Sunset.IsZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := m.now()
+			if !now.Before(d.Sunset) {
+				logging.Warn("Rejected request to sunset route", logging.Fields{"route": pattern})
+				http.Error(w, m.message("This API was retired on "+d.Sunset.UTC().Format("2006-01-02"), d), http.StatusGone)
+				return
+			}
+			for _, b := range m.brownouts {
+				if !now.Before(b.start) && now.Before(b.end) {
+					logging.Warn("Rejected request during sunset brownout", logging.Fields{"route": pattern})
+					header.Set("Retry-After", strconv.Itoa(int(b.end.Sub(now).Seconds())+1))
+					http.Error(w, m.message("This API is unavailable during a scheduled brownout ahead of its retirement on "+d.Sunset.UTC().Format("2006-01-02"), d), http.StatusGone)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (m *DeprecationMiddleware) message(msg string, d *Deprecation) string {
+	if d.Link != "" {
+		msg += "; see " + d.Link
+	}
+	return msg
+}
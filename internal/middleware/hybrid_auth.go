@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-031: Hybrid v1 authentication to migrate off X-Legacy-User-Id
+// AuthenticateHybrid authenticates with a JWT or API key when one is
+// presented. Otherwise, unless enforceJWT is set, it falls back to the
+// X-Legacy-User-Id header for callers on the allowlisted networks and
+// marks the request as legacy-authenticated for v1 usage tracking.
+func (m *AuthMiddleware) AuthenticateHybrid(route string, enforceJWT bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authenticated := m.Authenticate(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKeyFromRequest(r) != "" || r.Header.Get("Authorization") != "" {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			if enforceJWT {
+				http.Error(w, "JWT or API key required", http.StatusUnauthorized)
+				return
+			}
+
+			userID := r.Header.Get("X-Legacy-User-Id")
+			if userID == "" {
+				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+				return
+			}
+
+			sourceIP := remoteIP(r)
+			if !m.legacyNetworkAllowed(sourceIP) {
+				logging.Warn("Legacy auth fallback rejected", logging.Fields{
+					"user_id":   userID,
+					"source_ip": sourceIP,
+					"route":     route,
+				})
+				http.Error(w, "Legacy authentication not allowed from this network", http.StatusUnauthorized)
+				return
+			}
+
+			logging.Warn("Legacy auth fallback used", logging.Fields{
+				"user_id":   userID,
+				"source_ip": sourceIP,
+				"route":     route,
+			})
+
+			ctx := context.WithValue(r.Context(), ContextKeyUserID, userID)
+			ctx = context.WithValue(ctx, ContextKeyRole, "customer")
+			ctx = context.WithValue(ctx, ContextKeyLegacy, true)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (m *AuthMiddleware) legacyNetworkAllowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range m.legacyNets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
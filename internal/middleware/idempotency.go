@@ -0,0 +1,303 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-038: Idempotency-Key support for retried POSTs (orders, payments).
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotencyReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// Records keep whole responses, so the store is bounded three ways: in
+	// total, per caller, and by the size of each stored body. When a limit
+	// is reached the oldest completed records are evicted first.
+	maxIdempotencyRecords        = 10000
+	maxIdempotencyRecordsPerUser = 100
+	maxIdempotencyBodyBytes      = 64 << 10
+)
+
+var (
+	errIdempotencyUserBusy = errors.New("too many idempotent requests in progress for this caller")
+	errIdempotencyFull     = errors.New("too many idempotent requests in progress")
+)
+
+// IdempotencyMiddleware stores the first response for each key+user+body and
+// replays it for retries. Records live in memory for ttl.
+type IdempotencyMiddleware struct {
+	ttl     time.Duration
+	records map[string]*idempotencyRecord
+	order   *list.List            // all records, oldest first
+	users   map[string]*list.List // each caller's records, oldest first
+	mu      sync.Mutex
+}
+
+type idempotencyRecord struct {
+	key       string
+	userID    string
+	bodyHash  string
+	done      bool
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+
+	elem     *list.Element
+	userElem *list.Element
+}
+
+func NewIdempotencyMiddleware(ttl time.Duration) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		ttl:     ttl,
+		records: make(map[string]*idempotencyRecord),
+		order:   list.New(),
+		users:   make(map[string]*list.List),
+	}
+}
+
+// Enforce applies idempotency to requests carrying an Idempotency-Key
+// header. Requests without one pass through unchanged. It must run after
+// authentication so keys are scoped to the caller.
+func (m *IdempotencyMiddleware) Enforce(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		bodySum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(bodySum[:])
+		userID := GetUserIDFromContext(r.Context())
+		recordKey := idempotencyRecordKey(key, userID, r.Method, r.URL.Path)
+
+		record, existing, err := m.begin(recordKey, userID, bodyHash)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if err == errIdempotencyUserBusy {
+				status = http.StatusTooManyRequests
+			}
+			logging.Warn("Idempotency store refused request", logging.Fields{
+				"user_id": userID,
+				"path":    r.URL.Path,
+				"error":   err.Error(),
+			})
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many idempotent requests in progress, retry later", status)
+			return
+		}
+		if existing {
+			m.replay(w, r, key, record, bodyHash)
+			return
+		}
+
+		// A panicking handler must not leave the key stuck in flight.
+		defer func() {
+			if p := recover(); p != nil {
+				m.abandon(recordKey)
+				panic(p)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		m.finish(recordKey, rec)
+	})
+}
+
+// begin returns the record for key, creating an in-flight one when none
+// exists. existing reports whether the record was already present. To make
+// room it evicts the caller's, then anyone's, oldest completed record; it
+// fails only when every record in the way is still in flight.
+func (m *IdempotencyMiddleware) begin(key, userID, bodyHash string) (record idempotencyRecord, existing bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	if r, ok := m.records[key]; ok {
+		return *r, true, nil
+	}
+
+	if mine := m.users[userID]; mine != nil && mine.Len() >= maxIdempotencyRecordsPerUser {
+		if !m.evictOldest(mine) {
+			return idempotencyRecord{}, false, errIdempotencyUserBusy
+		}
+	}
+	if len(m.records) >= maxIdempotencyRecords {
+		if !m.evictOldest(m.order) {
+			return idempotencyRecord{}, false, errIdempotencyFull
+		}
+	}
+
+	r := &idempotencyRecord{
+		key:       key,
+		userID:    userID,
+		bodyHash:  bodyHash,
+		expiresAt: now.Add(m.ttl),
+	}
+	mine := m.users[userID]
+	if mine == nil {
+		mine = list.New()
+		m.users[userID] = mine
+	}
+	r.elem = m.order.PushBack(r)
+	r.userElem = mine.PushBack(r)
+	m.records[key] = r
+	return idempotencyRecord{}, false, nil
+}
+
+// abandon drops an in-flight record whose handler did not finish.
+func (m *IdempotencyMiddleware) abandon(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.records[key]; ok && !r.done {
+		m.remove(r)
+	}
+}
+
+// finish stores a completed response. Server errors are not stored so the
+// client can retry them, and neither are bodies over maxIdempotencyBodyBytes.
+func (m *IdempotencyMiddleware) finish(key string, rec *responseRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.records[key]
+	if !ok {
+		return
+	}
+	if rec.status >= http.StatusInternalServerError || rec.overflow {
+		if rec.overflow {
+			logging.Warn("Response too large to store for idempotent replay", logging.Fields{"user_id": r.userID})
+		}
+		m.remove(r)
+		return
+	}
+
+	r.done = true
+	r.status = rec.status
+	r.header = rec.Header().Clone()
+	r.body = rec.body.Bytes()
+}
+
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, key string, record idempotencyRecord, bodyHash string) {
+	fields := logging.Fields{
+		"idempotency_key": key,
+		"user_id":         GetUserIDFromContext(r.Context()),
+		"path":            r.URL.Path,
+	}
+
+	if record.bodyHash != bodyHash {
+		logging.Warn("Idempotency key reused with a different body", fields)
+		http.Error(w, "Idempotency-Key was used with a different request body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if !record.done {
+		logging.Warn("Idempotent request already in progress", fields)
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+
+	logging.Info("Replaying idempotent response", fields)
+
+	for name, values := range record.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderIdempotencyReplayed, "true")
+	w.WriteHeader(record.status)
+	w.Write(record.body)
+}
+
+// sweep drops expired records. Records share one ttl, so they expire in the
+// order they were created. Callers hold m.mu.
+func (m *IdempotencyMiddleware) sweep(now time.Time) {
+	for e := m.order.Front(); e != nil; e = m.order.Front() {
+		r := e.Value.(*idempotencyRecord)
+		if now.Before(r.expiresAt) {
+			return
+		}
+		m.remove(r)
+	}
+}
+
+// evictOldest removes the oldest completed record in l, reporting whether
+// there was one. Callers hold m.mu.
+func (m *IdempotencyMiddleware) evictOldest(l *list.List) bool {
+	for e := l.Front(); e != nil; e = e.Next() {
+		if r := e.Value.(*idempotencyRecord); r.done {
+			m.remove(r)
+			return true
+		}
+	}
+	return false
+}
+
+// remove drops r from the store. Callers hold m.mu.
+func (m *IdempotencyMiddleware) remove(r *idempotencyRecord) {
+	delete(m.records, r.key)
+	m.order.Remove(r.elem)
+	if mine := m.users[r.userID]; mine != nil {
+		mine.Remove(r.userElem)
+		if mine.Len() == 0 {
+			delete(m.users, r.userID)
+		}
+	}
+}
+
+func idempotencyRecordKey(key, userID, method, path string) string {
+	sum := sha256.Sum256([]byte(key + "\x00" + userID + "\x00" + method + " " + path))
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder passes a response through while keeping a copy of it,
+// up to maxIdempotencyBodyBytes.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(b) > maxIdempotencyBodyBytes {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
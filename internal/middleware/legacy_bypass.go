@@ -1,50 +1,275 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
-	"strings"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// SEC-1002: Temporary auth bypass for internal tools that haven't migrated
+// to new auth. Every entry is time-boxed and owned; see configs/legacy_bypass.json.
+
+const (
+	HeaderBypassTimestamp = "X-Bypass-Timestamp"
+	HeaderBypassSignature = "X-Bypass-Signature"
+
+	// bypassSignatureTolerance bounds clock skew and replay of signed requests.
+	bypassSignatureTolerance = 5 * time.Minute
 )
 
-// TODO(TEAM-SEC): CRITICAL - Remove this bypass before 2024-11-15
-// This bypass was added for internal tools that haven't migrated to new auth
-// Ticket: SEC-1002
-// Owner: platform-team
-// Approved by: security-team (temporary exception)
-
-// AllowedLegacyPaths lists paths that bypass modern authentication.
-// TODO(TEAM-SEC): Remove each path as tools are migrated
-var AllowedLegacyPaths = []string{
-	"/internal/legacy/health",
-	"/internal/legacy/metrics",
-	"/internal/tools/debug",  // TODO(TEAM-SEC): Remove after tools-v2 deployment
-}
-
-// LegacyAuthBypass temporarily bypasses auth for internal legacy tools.
-// DEPRECATED: This middleware should be removed after all tools migrate.
-func LegacyAuthBypass(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, path := range AllowedLegacyPaths {
-			if strings.HasPrefix(r.URL.Path, path) {
-				// WARNING: Bypassing authentication - temporary only!
-				log.Printf("WARNING: Auth bypass for legacy path: %s", r.URL.Path)
-				// TODO(TEAM-SEC): Log this to security audit trail
-				next.ServeHTTP(w, r)
-				return
+// BypassEntry grants unauthenticated access to a single exact path until
+// ExpiresAt, from AllowedCIDRs only, for requests signed with the shared
+// secret read from the SecretEnv environment variable.
+type BypassEntry struct {
+	Path         string    `json:"path"`
+	Owner        string    `json:"owner"`
+	Ticket       string    `json:"ticket"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	AllowedCIDRs []string  `json:"allowed_cidrs"`
+	SecretEnv    string    `json:"secret_env"`
+
+	secret []byte
+	nets   []*net.IPNet
+}
+
+// BypassStatus is the admin view of a bypass entry.
+type BypassStatus struct {
+	Path         string    `json:"path"`
+	Owner        string    `json:"owner"`
+	Ticket       string    `json:"ticket"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	AllowedCIDRs []string  `json:"allowed_cidrs"`
+	Active       bool      `json:"active"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// BypassRegistry holds the legacy auth bypass entries keyed by path.
+type BypassRegistry struct {
+	entries map[string]*BypassEntry
+	now     func() time.Time
+}
+
+// LoadBypassRegistry reads bypass entries from a JSON file. A missing file
+// yields an empty registry, so no path is bypassed.
+func LoadBypassRegistry(path string) (*BypassRegistry, error) {
+	reg := &BypassRegistry{
+		entries: make(map[string]*BypassEntry),
+		now:     time.Now,
+	}
+
+	if path == "" {
+		return reg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bypass registry: %w", err)
+	}
+
+	var entries []*BypassEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse bypass registry: %w", err)
+	}
+	for _, e := range entries {
+		if err := reg.Add(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return reg, nil
+}
+
+// Add validates and registers an entry.
+func (r *BypassRegistry) Add(e *BypassEntry) error {
+	if e.Path == "" || e.Owner == "" || e.ExpiresAt.IsZero() {
+		return fmt.Errorf("bypass entry %q requires path, owner and expires_at", e.Path)
+	}
+
+	for _, cidr := range e.AllowedCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("bypass entry %q: invalid cidr %q: %w", e.Path, cidr, err)
+		}
+		e.nets = append(e.nets, n)
+	}
+
+	if e.SecretEnv != "" {
+		e.secret = []byte(os.Getenv(e.SecretEnv))
+	}
+
+	r.entries[e.Path] = e
+	return nil
+}
+
+// Paths returns the registered bypass paths.
+func (r *BypassRegistry) Paths() []string {
+	paths := make([]string, 0, len(r.entries))
+	for p := range r.entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Status reports every entry and whether it is currently usable.
+func (r *BypassRegistry) Status() []BypassStatus {
+	now := r.now()
+	statuses := make([]BypassStatus, 0, len(r.entries))
+	for _, p := range r.Paths() {
+		e := r.entries[p]
+		reason := e.disabledReason(now)
+		statuses = append(statuses, BypassStatus{
+			Path:         e.Path,
+			Owner:        e.Owner,
+			Ticket:       e.Ticket,
+			ExpiresAt:    e.ExpiresAt,
+			AllowedCIDRs: e.AllowedCIDRs,
+			Active:       reason == "",
+			Reason:       reason,
+		})
+	}
+	return statuses
+}
+
+func (e *BypassEntry) disabledReason(now time.Time) string {
+	if !now.Before(e.ExpiresAt) {
+		return "expired"
+	}
+	if len(e.secret) == 0 {
+		return "shared secret not configured"
+	}
+	if len(e.nets) == 0 {
+		return "no allowed source networks"
+	}
+	return ""
+}
+
+// Bypass serves next without user authentication when the request matches
+// an active entry exactly and passes the source network and signature checks.
+// Every use and denial is written to the security audit trail.
+func (r *BypassRegistry) Bypass(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		sourceIP := remoteIP(req)
+
+		e, ok := r.entries[req.URL.Path]
+		if !ok {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		fields := logging.Fields{
+			"path":      req.URL.Path,
+			"method":    req.Method,
+			"owner":     e.Owner,
+			"ticket":    e.Ticket,
+			"source_ip": sourceIP,
+		}
+
+		if reason := e.disabledReason(r.now()); reason != "" {
+			fields["reason"] = reason
+			event := audit.EventBypassDenied
+			if reason == "expired" {
+				event = audit.EventBypassExpired
 			}
+			audit.Log(ctx, event, fields)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if !e.allowsIP(sourceIP) {
+			fields["reason"] = "source not allowed"
+			audit.Log(ctx, audit.EventBypassDenied, fields)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := e.verifySignature(req, r.now()); err != nil {
+			fields["reason"] = err.Error()
+			audit.Log(ctx, audit.EventBypassDenied, fields)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
 		}
-		// Normal auth flow continues
-		next.ServeHTTP(w, r)
+
+		audit.Log(ctx, audit.EventBypassUsed, fields)
+
+		ctx = context.WithValue(ctx, ContextKeyUserID, "bypass:"+e.Owner)
+		ctx = context.WithValue(ctx, ContextKeyRole, "service")
+
+		next.ServeHTTP(w, req.WithContext(ctx))
 	})
 }
 
-// IsLegacyPath checks if a path is in the legacy bypass list.
-// TODO(TEAM-SEC): Remove this function when bypass is removed
-func IsLegacyPath(path string) bool {
-	for _, p := range AllowedLegacyPaths {
-		if strings.HasPrefix(path, p) {
+func (e *BypassEntry) allowsIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range e.nets {
+		if n.Contains(parsed) {
 			return true
 		}
 	}
 	return false
 }
+
+// verifySignature checks X-Bypass-Signature, the hex HMAC-SHA256 of
+// "<timestamp>.<method>.<path>" under the entry's shared secret.
+func (e *BypassEntry) verifySignature(r *http.Request, now time.Time) error {
+	ts := r.Header.Get(HeaderBypassTimestamp)
+	sig := r.Header.Get(HeaderBypassSignature)
+	if ts == "" || sig == "" {
+		return errors.New("missing signature")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > bypassSignatureTolerance || skew < -bypassSignatureTolerance {
+		return errors.New("timestamp outside tolerance")
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+	if !hmac.Equal(got, SignBypass(e.secret, ts, r.Method, r.URL.Path)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// SignBypass computes the bypass signature for a request.
+func SignBypass(secret []byte, timestamp, method, path string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "." + method + "." + path))
+	return mac.Sum(nil)
+}
+
+// remoteIP returns the connection's peer address. Forwarding headers are
+// ignored because they are client-controlled.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,11 +1,30 @@
 package middleware_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/apikey"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/jwt"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/policy"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
 )
 
 func TestCorrelationMiddleware_AddRequestID(t *testing.T) {
@@ -70,3 +89,774 @@ func TestLoggingMiddleware_Log(t *testing.T) {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
 }
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	store, _ := apikey.NewStore("")
+	_, raw, _ := store.Create(apikey.CreateRequest{Owner: "partner-1", Scopes: []string{"orders:read"}})
+
+	mw := middleware.NewAuthMiddleware(&config.Config{JWTSecret: "test-secret", RateLimitRPS: 10}, store, nil)
+
+	handler := mw.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := middleware.GetUserIDFromContext(r.Context()); userID != "partner-1" {
+			t.Errorf("expected user ID 'partner-1', got '%s'", userID)
+		}
+		if scopes := middleware.GetScopesFromContext(r.Context()); len(scopes) != 1 {
+			t.Errorf("expected 1 scope, got %v", scopes)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-API-Key", raw)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-API-Key", "acme_unknown_secret")
+	w = httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthMiddleware_APIKeyWithoutScopes(t *testing.T) {
+	// A key stored before scopes were required has none, and is denied.
+	sum := sha256.Sum256([]byte("secret"))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	data := `[{"id":"old","hash":"` + hex.EncodeToString(sum[:]) + `","owner":"partner-9","created_at":"2024-01-01T00:00:00Z"}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write key store: %v", err)
+	}
+	store, err := apikey.NewStore(path)
+	if err != nil {
+		t.Fatalf("failed to load key store: %v", err)
+	}
+
+	mw := middleware.NewAuthMiddleware(&config.Config{JWTSecret: "test-secret"}, store, nil)
+	handler := mw.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mw.HasPermission(r.Context(), "orders:read") {
+			t.Error("expected a key without scopes to be denied")
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-API-Key", "acme_old_secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestAuthMiddleware_RequirePermission(t *testing.T) {
+	pol := &policy.Policy{Roles: map[string][]string{"customer": {"orders:read"}}}
+	mw := middleware.NewAuthMiddleware(&config.Config{JWTSecret: "test-secret"}, nil, pol)
+
+	handler := mw.RequirePermission("payments:refund")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/payments/1/refund", nil)
+	ctx := context.WithValue(req.Context(), middleware.ContextKeyRole, "customer")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req.WithContext(ctx))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "payments:refund") {
+		t.Errorf("expected missing permission in body, got '%s'", w.Body.String())
+	}
+}
+
+func TestOwnershipMiddleware_RequireOwner(t *testing.T) {
+	pol := &policy.Policy{Roles: map[string][]string{
+		"customer": {"orders:read"},
+		"support":  {"orders:read", "orders:any"},
+	}}
+	authMW := middleware.NewAuthMiddleware(&config.Config{JWTSecret: "test-secret"}, nil, pol)
+
+	lookups := 0
+	ownerMW := middleware.NewOwnershipMiddleware(authMW, map[string]middleware.OwnerLookup{
+		"orders": func(ctx context.Context, id string) (string, error) {
+			lookups++
+			if id == "missing" {
+				return "", middleware.ErrResourceNotFound
+			}
+			return "user_alice", nil
+		},
+	}, time.Minute)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /orders/{id}", ownerMW.RequireOwner("orders")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name   string
+		userID string
+		role   string
+		path   string
+		want   int
+	}{
+		{"owner", "user_alice", "customer", "/orders/o1", http.StatusOK},
+		{"other customer", "user_bob", "customer", "/orders/o1", http.StatusNotFound},
+		{"support exempt", "agent_1", "support", "/orders/o1", http.StatusOK},
+		{"missing order", "user_alice", "customer", "/orders/missing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			ctx := context.WithValue(req.Context(), middleware.ContextKeyUserID, tt.userID)
+			ctx = context.WithValue(ctx, middleware.ContextKeyRole, tt.role)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, req.WithContext(ctx))
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	if lookups != 2 {
+		t.Errorf("expected 2 upstream lookups with caching, got %d", lookups)
+	}
+}
+
+func TestBypassRegistry_Bypass(t *testing.T) {
+	t.Setenv("TEST_BYPASS_SECRET", "shared")
+
+	reg, _ := middleware.LoadBypassRegistry("")
+	reg.Add(&middleware.BypassEntry{
+		Path:         "/internal/legacy/health",
+		Owner:        "platform-team",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		SecretEnv:    "TEST_BYPASS_SECRET",
+	})
+	reg.Add(&middleware.BypassEntry{
+		Path:         "/internal/legacy/metrics",
+		Owner:        "platform-team",
+		ExpiresAt:    time.Now().Add(-time.Hour),
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		SecretEnv:    "TEST_BYPASS_SECRET",
+	})
+
+	handler := reg.Bypass(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	signed := func(path, remoteAddr string) *http.Request {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(middleware.HeaderBypassTimestamp, ts)
+		req.Header.Set(middleware.HeaderBypassSignature, hex.EncodeToString(middleware.SignBypass([]byte("shared"), ts, http.MethodGet, path)))
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"active entry", signed("/internal/legacy/health", "10.1.2.3:5000"), http.StatusOK},
+		{"expired entry", signed("/internal/legacy/metrics", "10.1.2.3:5000"), http.StatusForbidden},
+		{"outside cidr", signed("/internal/legacy/health", "192.168.1.1:5000"), http.StatusForbidden},
+		{"prefix match rejected", signed("/internal/legacy/health/x", "10.1.2.3:5000"), http.StatusForbidden},
+		{"unsigned", httptest.NewRequest(http.MethodGet, "/internal/legacy/health", nil), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	active := 0
+	for _, s := range reg.Status() {
+		if s.Active {
+			active++
+		}
+	}
+	if active != 1 {
+		t.Errorf("expected 1 active bypass, got %d", active)
+	}
+}
+
+func TestAuthMiddleware_AuthenticateHybrid(t *testing.T) {
+	cfg := &config.Config{JWTSecret: "test-secret", LegacyAuthAllowedCIDRs: []string{"10.0.0.0/8"}}
+	mw := middleware.NewAuthMiddleware(cfg, nil, nil)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	hybrid := mw.AuthenticateHybrid("GET /api/v1/users/{id}", false)(ok)
+	enforced := mw.AuthenticateHybrid("GET /api/v1/users/{id}", true)(ok)
+
+	token, _ := jwt.NewParser("test-secret").Generate("user_1", "a@example.com", "customer")
+
+	legacy := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/user_1", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Legacy-User-Id", "user_1")
+		return req
+	}
+	bearer := httptest.NewRequest(http.MethodGet, "/api/v1/users/user_1", nil)
+	bearer.Header.Set("Authorization", "Bearer "+token)
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		req     *http.Request
+		want    int
+	}{
+		{"jwt accepted", hybrid, bearer, http.StatusOK},
+		{"fallback from allowlisted network", hybrid, legacy("10.0.0.5:1234"), http.StatusOK},
+		{"fallback from public network", hybrid, legacy("203.0.113.9:1234"), http.StatusUnauthorized},
+		{"fallback disabled when enforced", enforced, legacy("10.0.0.5:1234"), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	marked := mw.AuthenticateHybrid("GET /api/v1/users/{id}", false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.GetLegacyAuthFromContext(r.Context()) {
+			t.Error("expected fallback request to be marked as legacy-authenticated")
+		}
+	}))
+	marked.ServeHTTP(httptest.NewRecorder(), legacy("10.0.0.5:1234"))
+}
+
+func TestMTLSIdentities_Authenticate(t *testing.T) {
+	ids, _ := middleware.LoadMTLSIdentities("")
+	ids.Add(middleware.ServiceIdentity{Name: "internal-tools", Role: "service", DNSName: "internal-tools.acme.internal"})
+
+	handler := ids.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetServiceIdentityFromContext(r.Context()); id != "internal-tools" {
+			t.Errorf("expected service identity 'internal-tools', got '%s'", id)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	withCert := func(cert *x509.Certificate) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/internal/health", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"known san", withCert(&x509.Certificate{DNSNames: []string{"internal-tools.acme.internal"}}), http.StatusOK},
+		{"unknown cert", withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "laptop"}}), http.StatusForbidden},
+		{"no tls", httptest.NewRequest(http.MethodGet, "/internal/health", nil), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminAuth_Authenticate(t *testing.T) {
+	handler := middleware.NewAdminAuth("ops-token").Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role := middleware.GetRoleFromContext(r.Context()); role != "admin" {
+			t.Errorf("expected role 'admin', got '%s'", role)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"valid token", "Bearer ops-token", http.StatusOK},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"missing token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddleware_Resolve(t *testing.T) {
+	cfg := &config.Config{
+		RequestTimeout: 30,
+		RouteTimeouts:  []string{"POST /api/v2/payments=20", "GET /bad=abc"},
+	}
+	m := middleware.NewTimeoutMiddleware(cfg)
+
+	tests := []struct {
+		name    string
+		pattern string
+		route   time.Duration
+		want    time.Duration
+	}{
+		{"override wins", "POST /api/v2/payments", 5 * time.Second, 20 * time.Second},
+		{"route table timeout", "GET /api/v2/orders/{id}", 5 * time.Second, 5 * time.Second},
+		{"global default", "GET /api/v2/orders/{id}", 0, 30 * time.Second},
+		{"invalid override ignored", "GET /bad", 0, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Resolve(tt.pattern, tt.route); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	handler := m.Deadline(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("expected request context to carry a deadline")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestIdempotencyMiddleware_Enforce(t *testing.T) {
+	m := middleware.NewIdempotencyMiddleware(time.Hour)
+
+	calls := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := m.Enforce(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Block") != "" {
+			close(started)
+			<-release
+		} else {
+			calls++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"pay_` + strconv.Itoa(calls) + `"}`))
+	}))
+
+	send := func(key, body string, block bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/payments", strings.NewReader(body))
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+		if block {
+			req.Header.Set("X-Block", "1")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, "user_1"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"amount":100}`, false)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", first.Code)
+	}
+
+	replay := send("key-1", `{"amount":100}`, false)
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("expected replay of %q, got %d %q", first.Body.String(), replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get(middleware.HeaderIdempotencyReplayed) != "true" {
+		t.Error("expected replayed response to be marked")
+	}
+	if calls != 1 {
+		t.Errorf("expected upstream to be called once, got %d", calls)
+	}
+
+	if w := send("key-1", `{"amount":999}`, false); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for different body, got %d", w.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		send("key-2", `{"amount":100}`, true)
+		close(done)
+	}()
+	<-started
+	if w := send("key-2", `{"amount":100}`, false); w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 while in flight, got %d", w.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestIdempotencyMiddleware_Quotas(t *testing.T) {
+	m := middleware.NewIdempotencyMiddleware(time.Hour)
+
+	calls := 0
+	handler := m.Enforce(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		if r.Header.Get("X-Large") != "" {
+			w.Write(bytes.Repeat([]byte("x"), 65<<10))
+		}
+	}))
+
+	send := func(userID, key string, large bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/orders", strings.NewReader(`{}`))
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+		if large {
+			req.Header.Set("X-Large", "1")
+		}
+		req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUserID, userID))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// One caller filling their quota evicts only their own oldest records.
+	for i := 0; i <= 100; i++ {
+		if w := send("user_a", "key-"+strconv.Itoa(i), false); w.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected status 201, got %d", i, w.Code)
+		}
+	}
+	if w := send("user_b", "key-0", false); w.Code != http.StatusCreated || w.Header().Get(middleware.HeaderIdempotencyReplayed) != "" {
+		t.Errorf("expected another caller's request to run, got %d", w.Code)
+	}
+	if w := send("user_a", "key-100", false); w.Header().Get(middleware.HeaderIdempotencyReplayed) != "true" {
+		t.Error("expected the newest record to be replayed")
+	}
+	before := calls
+	if w := send("user_a", "key-0", false); w.Header().Get(middleware.HeaderIdempotencyReplayed) != "" || calls != before+1 {
+		t.Error("expected the oldest record to have been evicted")
+	}
+
+	// Oversized responses are passed through but not stored.
+	send("user_c", "large", true)
+	if w := send("user_c", "large", true); w.Header().Get(middleware.HeaderIdempotencyReplayed) != "" {
+		t.Error("expected an oversized response not to be replayed")
+	}
+}
+
+func TestIdempotencyMiddleware_PanicClearsKey(t *testing.T) {
+	m := middleware.NewIdempotencyMiddleware(time.Hour)
+
+	fail := true
+	handler := m.Enforce(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			panic(http.ErrAbortHandler)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	send := func() (code int, panicked bool) {
+		defer func() {
+			if recover() != nil {
+				panicked = true
+			}
+		}()
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/orders", strings.NewReader(`{}`))
+		req.Header.Set(middleware.HeaderIdempotencyKey, "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code, false
+	}
+
+	if _, panicked := send(); !panicked {
+		t.Fatal("expected the handler panic to propagate")
+	}
+
+	fail = false
+	if code, _ := send(); code != http.StatusCreated {
+		t.Errorf("expected retry after panic to run, got %d", code)
+	}
+}
+
+func TestWebhookVerifier_Verify(t *testing.T) {
+	v := middleware.NewWebhookVerifier([]string{"old-secret", "new-secret"}, 5*time.Minute)
+
+	failNext := false
+	handler := v.Verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failNext {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sign := func(secret string, ts time.Time, body string) string {
+		t := strconv.FormatInt(ts.Unix(), 10)
+		return "t=" + t + ",v1=" + hex.EncodeToString(middleware.SignWebhook([]byte(secret), t, []byte(body)))
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		failNext  bool
+		want      int
+	}{
+		{"missing signature", `{"id":"evt_1"}`, "", false, http.StatusBadRequest},
+		{"wrong secret", `{"id":"evt_1"}`, sign("other", now, `{"id":"evt_1"}`), false, http.StatusBadRequest},
+		{"tampered body", `{"id":"evt_1","amount":1}`, sign("new-secret", now, `{"id":"evt_1"}`), false, http.StatusBadRequest},
+		{"stale timestamp", `{"id":"evt_1"}`, sign("new-secret", now.Add(-10*time.Minute), `{"id":"evt_1"}`), false, http.StatusBadRequest},
+		{"missing event id", `{}`, sign("new-secret", now, `{}`), false, http.StatusBadRequest},
+		{"upstream failure", `{"id":"evt_1"}`, sign("new-secret", now, `{"id":"evt_1"}`), true, http.StatusServiceUnavailable},
+		{"retry after failure", `{"id":"evt_1"}`, sign("new-secret", now, `{"id":"evt_1"}`), false, http.StatusOK},
+		{"replayed event", `{"id":"evt_1"}`, sign("new-secret", now, `{"id":"evt_1"}`), false, http.StatusBadRequest},
+		{"rotated secret", `{"id":"evt_2"}`, sign("old-secret", now, `{"id":"evt_2"}`), false, http.StatusOK},
+
+		{"oversized body", `{"id":"evt_3","pad":"` + strings.Repeat("x", 1<<20) + `"}`, "t=1,v1=00", false, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failNext = tt.failNext
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/payments/webhook", strings.NewReader(tt.body))
+			if tt.signature != "" {
+				req.Header.Set(middleware.HeaderStripeSignature, tt.signature)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestValidationMiddleware_Validate(t *testing.T) {
+	s, err := schema.Parse([]byte(`{"type":"object","required":["order_id","amount"],"properties":{"amount":{"type":"number","minimum":0}}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	reg, _ := schema.LoadDir("")
+	reg.Add("payment_create", s)
+
+	tests := []struct {
+		name       string
+		mode       string
+		reportOnly []string
+		body       string
+		want       int
+		violations int
+	}{
+		{"valid", middleware.ValidationEnforce, nil, `{"order_id":"o","amount":5}`, http.StatusOK, 0},
+		{"all violations reported", middleware.ValidationEnforce, nil, `{"amount":-5}`, http.StatusBadRequest, 2},
+		{"malformed json", middleware.ValidationEnforce, nil, `{`, http.StatusBadRequest, 1},
+		{"report mode", middleware.ValidationReport, nil, `{"amount":-5}`, http.StatusOK, 0},
+		{"report-only schema", middleware.ValidationEnforce, []string{"payment_create"}, `{"amount":-5}`, http.StatusOK, 0},
+		{"off", middleware.ValidationOff, nil, `{"amount":-5}`, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{SchemaValidationMode: tt.mode, SchemaReportOnly: tt.reportOnly}
+			m := middleware.NewValidationMiddleware(reg, cfg)
+			handler := m.Validate("payment_create")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/payments", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, w.Code)
+			}
+			if tt.violations > 0 {
+				var resp middleware.ValidationError
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if len(resp.Violations) != tt.violations {
+					t.Errorf("expected %d violations, got %v", tt.violations, resp.Violations)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseValidationMiddleware_Validate(t *testing.T) {
+	s, err := schema.Parse([]byte(`{"type":"object","required":["id","user_id"],"properties":{"total":{"type":"number"}}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	reg, _ := schema.LoadDir("")
+	reg.Add("order", s)
+
+	upstream := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(body))
+		})
+	}
+
+	tests := []struct {
+		name string
+		mode string
+		body string
+		want int
+	}{
+		{"valid", middleware.ResponseValidationBlock, `{"id":"o1","user_id":"u1"}`, http.StatusOK},
+		{"observe passes drift through", middleware.ResponseValidationObserve, `{"id":"o1","total":"12","email":"a@b.c"}`, http.StatusOK},
+		{"block rejects drift", middleware.ResponseValidationBlock, `{"id":"o1","total":"12","email":"a@b.c"}`, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{ResponseValidationMode: tt.mode, ResponseSampleKeepKeys: []string{"id"}}
+			m := middleware.NewResponseValidationMiddleware(reg, cfg)
+			handler := m.Validate("GET /api/v2/orders/{id}", "order")(upstream(tt.body))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/orders/o1", nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, w.Code)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != tt.body {
+				t.Errorf("expected body to pass through, got %q", w.Body.String())
+			}
+
+			drift := m.Drift()
+			if len(drift) != 1 || drift[0].Checked != 1 {
+				t.Fatalf("expected one checked route, got %+v", drift)
+			}
+			if tt.name == "valid" {
+				if drift[0].Violations != 0 {
+					t.Errorf("expected no violations, got %d", drift[0].Violations)
+				}
+				return
+			}
+			if drift[0].Violations != 1 || len(drift[0].LastViolations) != 2 {
+				t.Errorf("expected 1 violating response with 2 violations, got %+v", drift[0])
+			}
+			if got := string(drift[0].Sample); got != `{"email":"string","id":"o1","total":"string"}` {
+				t.Errorf("expected sample reduced to its structure, got %s", got)
+			}
+		})
+	}
+}
+
+func TestDeprecationMiddleware_Signal(t *testing.T) {
+	now := time.Now().UTC()
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	brownout := now.Add(-time.Minute).Format(time.RFC3339) + "/" + now.Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		cfg        *config.Config
+		dep        middleware.Deprecation
+		wantStatus int
+	}{
+		{"headers only", &config.Config{}, middleware.Deprecation{Since: since, Sunset: now.Add(-time.Hour), Link: "https://docs.example.com/v1"}, http.StatusOK},
+		{"sunset enforced", &config.Config{SunsetEnforce: true}, middleware.Deprecation{Since: since, Sunset: now.Add(-time.Hour), Link: "https://docs.example.com/v1"}, http.StatusGone},
+		{"before sunset", &config.Config{SunsetEnforce: true}, middleware.Deprecation{Since: since, Sunset: now.Add(24 * time.Hour), Link: "https://docs.example.com/v1"}, http.StatusOK},
+		{"brownout", &config.Config{SunsetEnforce: true, SunsetBrownouts: []string{brownout}}, middleware.Deprecation{Since: since, Sunset: now.Add(24 * time.Hour), Link: "https://docs.example.com/v1"}, http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := middleware.NewDeprecationMiddleware(tt.cfg)
+			handler := m.Signal("GET /api/v1/users/{id}", &tt.dep)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/users/1", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Deprecation"); got != "@1672531200" {
+				t.Errorf("unexpected Deprecation header %q", got)
+			}
+			if got := w.Header().Get("Sunset"); got != tt.dep.Sunset.Format(http.TimeFormat) {
+				t.Errorf("unexpected Sunset header %q", got)
+			}
+			if got := w.Header().Get("Link"); got != `<https://docs.example.com/v1>; rel="deprecation sunset"; type="text/html"` {
+				t.Errorf("unexpected Link header %q", got)
+			}
+		})
+	}
+}
+
+func TestUsageMiddleware_Track(t *testing.T) {
+	tests := []struct {
+		name     string
+		identify bool
+		userID   string
+		peer     string
+		xff      string
+		want     usage.Caller
+	}{
+		{"rejected before auth", false, "", "10.0.0.5:4000", "203.0.113.7", usage.Caller{ClientIP: "203.0.113.7", UserAgent: "client/1.0"}},
+		{"authenticated", true, "user-1", "10.0.0.5:4000", "203.0.113.7", usage.Caller{Auth: usage.AuthJWT, UserID: "user-1", ClientIP: "203.0.113.7", UserAgent: "client/1.0"}},
+		{"untrusted peer", false, "", "198.51.100.9:4000", "203.0.113.7", usage.Caller{ClientIP: "198.51.100.9", UserAgent: "client/1.0"}},
+		{"spoofed hop before proxy", false, "", "10.0.0.5:4000", "1.2.3.4, 203.0.113.7, 10.0.0.6", usage.Caller{ClientIP: "203.0.113.7", UserAgent: "client/1.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, _ := usage.Open("")
+			m := middleware.NewUsageMiddleware(tracker, []string{"10.0.0.0/8"})
+
+			var inner http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusGone)
+			})
+			if tt.identify {
+				authed := m.Identify(inner)
+				inner = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					ctx := context.WithValue(r.Context(), middleware.ContextKeyUserID, tt.userID)
+					authed.ServeHTTP(w, r.WithContext(ctx))
+				})
+			}
+			handler := m.Track("GET /api/v1/users/{id}")(inner)
+
+			req := httptest.NewRequest("GET", "/api/v1/users/1", nil)
+			req.RemoteAddr = tt.peer
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			req.Header.Set("User-Agent", "client/1.0")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			records := tracker.Snapshot()
+			if len(records) != 1 {
+				t.Fatalf("expected 1 record, got %d", len(records))
+			}
+			rec := records[0]
+			got := usage.Caller{Auth: rec.Auth, UserID: rec.UserID, APIKeyID: rec.APIKeyID, ClientIP: rec.ClientIP, UserAgent: rec.UserAgent}
+			if got != tt.want {
+				t.Errorf("expected caller %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// ContextKeyServiceIdentity holds the service name of an mTLS-authenticated caller.
+const ContextKeyServiceIdentity contextKey = "service_identity"
+
+// ServiceIdentity maps a client certificate to a gateway identity. A
+// certificate matches when any of SubjectCN, DNSName or URI equals the
+// corresponding certificate field.
+type ServiceIdentity struct {
+	Name      string   `json:"name"`
+	Role      string   `json:"role"`
+	Scopes    []string `json:"scopes,omitempty"`
+	SubjectCN string   `json:"subject_cn,omitempty"`
+	DNSName   string   `json:"dns_san,omitempty"`
+	URI       string   `json:"uri_san,omitempty"`
+}
+
+// USER-033: Mutual TLS client authentication for internal callers
+// MTLSIdentities resolves verified client certificates to service identities.
+type MTLSIdentities struct {
+	identities []ServiceIdentity
+}
+
+// LoadMTLSIdentities reads the identity mapping from a JSON file. A missing
+// file yields an empty mapping, which rejects every certificate.
+func LoadMTLSIdentities(path string) (*MTLSIdentities, error) {
+	ids := &MTLSIdentities{}
+
+	if path == "" {
+		return ids, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mtls identities: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &ids.identities); err != nil {
+		return nil, fmt.Errorf("failed to parse mtls identities: %w", err)
+	}
+	for _, id := range ids.identities {
+		if id.Name == "" || (id.SubjectCN == "" && id.DNSName == "" && id.URI == "") {
+			return nil, fmt.Errorf("mtls identity %q requires a name and a certificate selector", id.Name)
+		}
+	}
+
+	return ids, nil
+}
+
+// Add registers an identity mapping.
+func (m *MTLSIdentities) Add(id ServiceIdentity) {
+	m.identities = append(m.identities, id)
+}
+
+// Resolve returns the identity for a verified leaf certificate.
+func (m *MTLSIdentities) Resolve(cert *x509.Certificate) (*ServiceIdentity, bool) {
+	for i := range m.identities {
+		id := &m.identities[i]
+		if id.SubjectCN != "" && id.SubjectCN == cert.Subject.CommonName {
+			return id, true
+		}
+		if id.DNSName != "" {
+			for _, name := range cert.DNSNames {
+				if name == id.DNSName {
+					return id, true
+				}
+			}
+		}
+		if id.URI != "" {
+			for _, uri := range cert.URIs {
+				if uri.String() == id.URI {
+					return id, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// Authenticate requires a client certificate verified by the TLS listener
+// and mapped to a known service identity.
+func (m *MTLSIdentities) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			http.Error(w, "Client certificate required", http.StatusUnauthorized)
+			return
+		}
+
+		leaf := r.TLS.VerifiedChains[0][0]
+		id, ok := m.Resolve(leaf)
+		if !ok {
+			logging.Warn("Unknown client certificate", logging.Fields{
+				"subject": leaf.Subject.String(),
+				"path":    r.URL.Path,
+			})
+			http.Error(w, "Unknown client certificate", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ContextKeyUserID, id.Name)
+		ctx = context.WithValue(ctx, ContextKeyRole, id.Role)
+		ctx = context.WithValue(ctx, ContextKeyServiceIdentity, id.Name)
+		if id.Scopes != nil {
+			ctx = context.WithValue(ctx, ContextKeyScopes, id.Scopes)
+		}
+
+		logging.Info("Request authenticated", logging.Fields{
+			"user_id":          id.Name,
+			"role":             id.Role,
+			"service_identity": id.Name,
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetServiceIdentityFromContext retrieves the mTLS service identity from the request context.
+func GetServiceIdentityFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ContextKeyServiceIdentity).(string); ok {
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+var (
+	// ErrResourceNotFound is returned by an OwnerLookup when the resource does not exist.
+	ErrResourceNotFound = errors.New("resource not found")
+
+	// ErrLookupDeadlineExceeded is returned by an OwnerLookup when the
+	// request's budget runs out, and answered with 504 like upstream calls.
+	ErrLookupDeadlineExceeded = errors.New("owner lookup deadline exceeded")
+)
+
+// OwnerLookup resolves the user ID that owns a resource.
+type OwnerLookup func(ctx context.Context, id string) (string, error)
+
+// USER-029: Resource ownership enforcement for user-scoped endpoints
+// OwnershipMiddleware rejects requests for resources the caller does not
+// own with the same 404 as for missing ones, so IDs belonging to others
+// cannot be probed. Callers holding "<resource>:any" under the policy are
+// exempt.
+type OwnershipMiddleware struct {
+	authMW  *AuthMiddleware
+	lookups map[string]OwnerLookup
+	ttl     time.Duration
+	mu      sync.Mutex
+	cache   map[string]ownerEntry
+}
+
+type ownerEntry struct {
+	owner   string
+	expires time.Time
+}
+
+func NewOwnershipMiddleware(authMW *AuthMiddleware, lookups map[string]OwnerLookup, ttl time.Duration) *OwnershipMiddleware {
+	return &OwnershipMiddleware{
+		authMW:  authMW,
+		lookups: lookups,
+		ttl:     ttl,
+		cache:   make(map[string]ownerEntry),
+	}
+}
+
+// RequireOwner checks the {id} path value against the owner of resource.
+// For "users" the ID is compared directly with the caller's user ID; other
+// resources are resolved through the registered OwnerLookup.
+func (m *OwnershipMiddleware) RequireOwner(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if m.authMW.HasPermission(ctx, resource+":any") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID := GetUserIDFromContext(ctx)
+			id := r.PathValue("id")
+
+			owner, err := m.owner(ctx, resource, id)
+			if errors.Is(err, ErrResourceNotFound) {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logging.Error("Ownership lookup failed", logging.Fields{
+					"resource": resource,
+					"id":       id,
+					"error":    err.Error(),
+				})
+				if errors.Is(err, ErrLookupDeadlineExceeded) {
+					http.Error(w, "Gateway timeout", http.StatusGatewayTimeout)
+					return
+				}
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			if userID == "" || owner != userID {
+				logging.Warn("Ownership check denied", logging.Fields{
+					"user_id":  userID,
+					"resource": resource,
+					"id":       id,
+					"path":     r.URL.Path,
+				})
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (m *OwnershipMiddleware) owner(ctx context.Context, resource, id string) (string, error) {
+	if resource == "users" {
+		return id, nil
+	}
+
+	lookup, ok := m.lookups[resource]
+	if !ok {
+		return "", errors.New("no owner lookup registered for " + resource)
+	}
+
+	cacheKey := resource + "/" + id
+	now := time.Now()
+
+	m.mu.Lock()
+	entry, ok := m.cache[cacheKey]
+	m.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.owner, nil
+	}
+
+	owner, err := lookup(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	m.cache[cacheKey] = ownerEntry{owner: owner, expires: now.Add(m.ttl)}
+	for k, e := range m.cache {
+		if now.After(e.expires) {
+			delete(m.cache, k)
+		}
+	}
+	m.mu.Unlock()
+
+	return owner, nil
+}
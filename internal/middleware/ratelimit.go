@@ -15,6 +15,7 @@ type RateLimitMiddleware struct {
 	clients  map[string]*clientLimit
 	mu       sync.RWMutex
 	cleanupC chan struct{}
+	stopOnce sync.Once
 }
 
 type clientLimit struct {
@@ -52,25 +53,31 @@ func (m *RateLimitMiddleware) Limit(next http.Handler) http.Handler {
 }
 
 func (m *RateLimitMiddleware) allow(clientIP string) bool {
+	return m.AllowKey(clientIP, m.config.RateLimitRPS)
+}
+
+// AllowKey takes a token from the bucket identified by key, refilling it at
+// rps tokens per second.
+func (m *RateLimitMiddleware) AllowKey(key string, rps int) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	now := time.Now()
-	client, exists := m.clients[clientIP]
+	client, exists := m.clients[key]
 
 	if !exists {
-		m.clients[clientIP] = &clientLimit{
-			tokens:     m.config.RateLimitRPS - 1,
+		m.clients[key] = &clientLimit{
+			tokens:     rps - 1,
 			lastRefill: now,
 		}
 		return true
 	}
 
 	elapsed := now.Sub(client.lastRefill)
-	refillTokens := int(elapsed.Seconds()) * m.config.RateLimitRPS
+	refillTokens := int(elapsed.Seconds()) * rps
 
 	if refillTokens > 0 {
-		client.tokens = min(m.config.RateLimitRPS, client.tokens+refillTokens)
+		client.tokens = min(rps, client.tokens+refillTokens)
 		client.lastRefill = now
 	}
 
@@ -82,6 +89,13 @@ func (m *RateLimitMiddleware) allow(clientIP string) bool {
 	return false
 }
 
+// Stop ends the background cleanup goroutine. It is safe to call more than once.
+func (m *RateLimitMiddleware) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.cleanupC)
+	})
+}
+
 func (m *RateLimitMiddleware) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Response validation modes for RESPONSE_VALIDATION_MODE.
+const (
+	ResponseValidationOff     = "off"
+	ResponseValidationObserve = "observe"
+	ResponseValidationBlock   = "block"
+
+	maxDriftSampleBytes = 2048
+)
+
+// ContractDrift summarises schema violations seen in one route's upstream
+// responses. Sample is the most recent offending payload reduced to its
+// structure: every scalar is replaced by its JSON type unless its key is in
+// RESPONSE_SAMPLE_KEEP_KEYS.
+type ContractDrift struct {
+	Route          string             `json:"route"`
+	Schema         string             `json:"schema"`
+	Checked        int64              `json:"checked"`
+	Violations     int64              `json:"violations"`
+	LastSeen       time.Time          `json:"last_seen,omitempty"`
+	LastViolations []schema.Violation `json:"last_violations,omitempty"`
+	Sample         json.RawMessage    `json:"sample,omitempty"`
+}
+
+// USER-043: Upstream response validation and contract drift detection
+// ResponseValidationMiddleware checks successful JSON responses against the
+// route's response schema. In observe mode responses pass through
+// unchanged; in block mode an invalid response becomes a 502.
+type ResponseValidationMiddleware struct {
+	schemas  *schema.Registry
+	mode     string
+	keepKeys map[string]bool
+
+	mu    sync.Mutex
+	drift map[string]*ContractDrift
+}
+
+func NewResponseValidationMiddleware(schemas *schema.Registry, cfg *config.Config) *ResponseValidationMiddleware {
+	keepKeys := make(map[string]bool)
+	for _, key := range cfg.ResponseSampleKeepKeys {
+		keepKeys[strings.ToLower(key)] = true
+	}
+
+	return &ResponseValidationMiddleware{
+		schemas:  schemas,
+		mode:     cfg.ResponseValidationMode,
+		keepKeys: keepKeys,
+		drift:    make(map[string]*ContractDrift),
+	}
+}
+
+// Validate checks responses for route against the named schema.
+func (m *ResponseValidationMiddleware) Validate(route, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		s, ok := m.schemas.Get(name)
+		if !ok || (m.mode != ResponseValidationObserve && m.mode != ResponseValidationBlock) {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			buf := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			if buf.status >= 200 && buf.status < 300 && strings.Contains(buf.header.Get("Content-Type"), "json") {
+				violations := m.check(route, name, s, buf.body.Bytes())
+				if len(violations) > 0 && m.mode == ResponseValidationBlock {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadGateway)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error":   "upstream_contract_violation",
+						"message": "Upstream response did not match its schema",
+					})
+					return
+				}
+			}
+
+			buf.copyTo(w)
+		})
+	}
+}
+
+func (m *ResponseValidationMiddleware) check(route, name string, s *schema.Schema, body []byte) []schema.Violation {
+	var doc interface{}
+	var violations []schema.Violation
+	if err := json.Unmarshal(body, &doc); err != nil {
+		violations = []schema.Violation{{Path: "", Message: "must be valid JSON"}}
+	} else {
+		violations = s.Validate(doc)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.drift[route]
+	if !ok {
+		d = &ContractDrift{Route: route, Schema: name}
+		m.drift[route] = d
+	}
+	d.Checked++
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	d.Violations++
+	d.LastSeen = time.Now().UTC()
+	d.LastViolations = violations
+	d.Sample = m.sample(doc)
+
+	logging.Warn("Upstream response violates schema", logging.Fields{
+		"route":      route,
+		"schema":     name,
+		"violations": violations,
+		"mode":       m.mode,
+	})
+
+	return violations
+}
+
+// sample redacts doc and truncates it for storage.
+func (m *ResponseValidationMiddleware) sample(doc interface{}) json.RawMessage {
+	data, err := json.Marshal(m.redact(doc, false))
+	if err != nil {
+		return nil
+	}
+	if len(data) > maxDriftSampleBytes {
+		data, _ = json.Marshal(string(data[:maxDriftSampleBytes]) + "...")
+	}
+	return data
+}
+
+// redact replaces scalars with their JSON type name. keep leaves the
+// scalars directly under an allowlisted key, or in an array under one.
+func (m *ResponseValidationMiddleware) redact(v interface{}, keep bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = m.redact(child, m.keepKeys[strings.ToLower(k)])
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = m.redact(child, keep)
+		}
+		return out
+	}
+	if keep {
+		return v
+	}
+
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// Drift returns per-route validation counters, most violations first.
+func (m *ResponseValidationMiddleware) Drift() []ContractDrift {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]ContractDrift, 0, len(m.drift))
+	for _, d := range m.drift {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Violations != out[j].Violations {
+			return out[i].Violations > out[j].Violations
+		}
+		return out[i].Route < out[j].Route
+	})
+	return out
+}
+
+// bufferedResponse holds a complete response so it can be inspected before
+// anything reaches the client.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *bufferedResponse) copyTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
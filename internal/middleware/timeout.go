@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-037: Per-route request budgets. The deadline set here bounds every
+// upstream call made while serving the route; proxy.Client forwards what is
+// left of it so upstreams can abandon work the gateway will not wait for.
+type TimeoutMiddleware struct {
+	defaultTimeout time.Duration
+	overrides      map[string]time.Duration
+}
+
+// NewTimeoutMiddleware reads ROUTE_TIMEOUTS entries of the form
+// "METHOD /path=seconds", keyed by route pattern.
+func NewTimeoutMiddleware(cfg *config.Config) *TimeoutMiddleware {
+	overrides := make(map[string]time.Duration)
+	for _, entry := range cfg.RouteTimeouts {
+		pattern, value, ok := strings.Cut(entry, "=")
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || seconds <= 0 {
+			logging.Warnf("Ignoring invalid route timeout %q", entry)
+			continue
+		}
+		overrides[strings.TrimSpace(pattern)] = time.Duration(seconds) * time.Second
+	}
+
+	return &TimeoutMiddleware{
+		defaultTimeout: time.Duration(cfg.RequestTimeout) * time.Second,
+		overrides:      overrides,
+	}
+}
+
+// Resolve returns the budget for a route: a configured override first, then
+// the route table's own timeout, then REQUEST_TIMEOUT_SECONDS.
+func (m *TimeoutMiddleware) Resolve(pattern string, routeTimeout time.Duration) time.Duration {
+	if d, ok := m.overrides[pattern]; ok {
+		return d
+	}
+	if routeTimeout > 0 {
+		return routeTimeout
+	}
+	return m.defaultTimeout
+}
+
+// Deadline attaches a deadline of d to the request context.
+func (m *TimeoutMiddleware) Deadline(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
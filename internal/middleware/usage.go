@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// USER-050: UsageMiddleware records who calls deprecated routes. Track
+// wraps the whole route so requests rejected at sunset, during brownouts or
+// by authentication are counted too; Identify runs after authentication and
+// adds whatever identity the request turned out to carry.
+type UsageMiddleware struct {
+	tracker *usage.Tracker
+	proxies []*net.IPNet
+}
+
+type usageCallerKey struct{}
+
+// NewUsageMiddleware records callers in tracker. X-Forwarded-For is only
+// believed when the connection comes from one of the trustedProxies CIDRs.
+func NewUsageMiddleware(tracker *usage.Tracker, trustedProxies []string) *UsageMiddleware {
+	var proxies []*net.IPNet
+	for _, cidr := range trustedProxies {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logging.Warnf("Ignoring invalid trusted proxy CIDR %q: %v", cidr, err)
+			continue
+		}
+		proxies = append(proxies, n)
+	}
+	return &UsageMiddleware{tracker: tracker, proxies: proxies}
+}
+
+// Track counts each request to the route under its caller once the request
+// has been served.
+func (m *UsageMiddleware) Track(pattern string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			caller := &usage.Caller{ClientIP: m.clientIP(r), UserAgent: r.UserAgent()}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), usageCallerKey{}, caller)))
+			m.tracker.Record(pattern, *caller)
+		})
+	}
+}
+
+// Identify copies the authenticated caller into the record Track is
+// building for this request.
+func (m *UsageMiddleware) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caller, ok := r.Context().Value(usageCallerKey{}).(*usage.Caller); ok {
+			caller.Auth = authMethod(r)
+			caller.UserID = GetUserIDFromContext(r.Context())
+			caller.APIKeyID = GetAPIKeyIDFromContext(r.Context())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the connection peer. When the peer is a trusted proxy it
+// walks X-Forwarded-For from the right and returns the first address not
+// added by a trusted proxy, so entries a client prepends are ignored.
+func (m *UsageMiddleware) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !m.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !m.trusted(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (m *UsageMiddleware) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range m.proxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func authMethod(r *http.Request) string {
+	ctx := r.Context()
+	switch {
+	case GetLegacyAuthFromContext(ctx):
+		return usage.AuthLegacyHeader
+	case GetAPIKeyIDFromContext(ctx) != "":
+		return usage.AuthAPIKey
+	case GetUserIDFromContext(ctx) != "":
+		return usage.AuthJWT
+	}
+	return ""
+}
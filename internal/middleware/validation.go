@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Request validation modes for SCHEMA_VALIDATION_MODE.
+const (
+	ValidationEnforce = "enforce"
+	ValidationReport  = "report"
+	ValidationOff     = "off"
+)
+
+// ValidationError is the 400 body returned when a request fails its schema.
+type ValidationError struct {
+	Error      string             `json:"error"`
+	Message    string             `json:"message"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+// USER-041: Request body validation against per-route JSON Schemas
+type ValidationMiddleware struct {
+	schemas    *schema.Registry
+	mode       string
+	reportOnly map[string]bool
+}
+
+func NewValidationMiddleware(schemas *schema.Registry, cfg *config.Config) *ValidationMiddleware {
+	reportOnly := make(map[string]bool)
+	for _, name := range cfg.SchemaReportOnly {
+		reportOnly[name] = true
+	}
+
+	return &ValidationMiddleware{
+		schemas:    schemas,
+		mode:       cfg.SchemaValidationMode,
+		reportOnly: reportOnly,
+	}
+}
+
+// Validate checks the request body against the named schema. In report
+// mode, or for schemas listed in SCHEMA_REPORT_ONLY, violations are logged
+// and the request continues.
+func (m *ValidationMiddleware) Validate(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		s, ok := m.schemas.Get(name)
+		if !ok || m.mode == ValidationOff {
+			if !ok {
+				logging.Warnf("Schema %q not found, requests will not be validated", name)
+			}
+			return next
+		}
+
+		enforce := m.mode == ValidationEnforce && !m.reportOnly[name]
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var doc interface{}
+			var violations []schema.Violation
+			if err := json.Unmarshal(body, &doc); err != nil {
+				violations = []schema.Violation{{Path: "", Message: "must be valid JSON"}}
+			} else {
+				violations = s.Validate(doc)
+			}
+
+			if len(violations) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logging.Warn("Request failed schema validation", logging.Fields{
+				"schema":     name,
+				"path":       r.URL.Path,
+				"violations": violations,
+				"enforced":   enforce,
+			})
+
+			if !enforce {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ValidationError{
+				Error:      "invalid_request",
+				Message:    "Request body failed validation",
+				Violations: violations,
+			})
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Webhook signature headers, checked in order. Both use the
+// "t=<unix>,v1=<hex>[,v1=<hex>]" format, signing "<t>.<raw body>".
+const (
+	HeaderStripeSignature  = "X-Stripe-Signature"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+const (
+	// maxWebhookBodyBytes caps what is read before the signature is checked.
+	maxWebhookBodyBytes = 1 << 20
+
+	webhookPruneInterval = time.Minute
+)
+
+var (
+	ErrMissingSignature    = errors.New("missing webhook signature")
+	ErrInvalidSignature    = errors.New("invalid webhook signature")
+	ErrTimestampOutOfRange = errors.New("webhook timestamp outside tolerance")
+	ErrMissingEventID      = errors.New("webhook payload has no event id")
+	ErrDuplicateEvent      = errors.New("duplicate webhook event")
+)
+
+// USER-039: Payment webhook signature verification
+// WebhookVerifier checks provider signatures against every active secret so
+// secrets can be rotated without downtime, and drops replayed event IDs.
+type WebhookVerifier struct {
+	secrets   [][]byte
+	tolerance time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewWebhookVerifier(secrets []string, tolerance time.Duration) *WebhookVerifier {
+	v := &WebhookVerifier{
+		tolerance: tolerance,
+		seen:      make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+	for _, s := range secrets {
+		v.secrets = append(v.secrets, []byte(s))
+	}
+	if len(v.secrets) == 0 {
+		logging.Warnf("No webhook secrets configured; all webhooks will be rejected")
+	}
+	return v
+}
+
+// Verify rejects webhooks with a 400 unless the signature, timestamp and
+// event ID all check out. An event ID is only remembered once the wrapped
+// handler succeeds, so provider retries after a failure are let through.
+func (v *WebhookVerifier) Verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Webhook body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		signature := r.Header.Get(HeaderStripeSignature)
+		if signature == "" {
+			signature = r.Header.Get(HeaderWebhookSignature)
+		}
+
+		eventID, err := v.check(signature, body, time.Now())
+		if err == nil {
+			err = v.reserve(eventID)
+		}
+		if err != nil {
+			logging.Warn("Webhook rejected", logging.Fields{
+				"error":     err.Error(),
+				"event_id":  eventID,
+				"client_ip": getClientIP(r),
+			})
+			http.Error(w, "Invalid webhook: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		if rw.statusCode >= 300 {
+			v.release(eventID)
+		}
+	})
+}
+
+// check validates the signature header against body and returns the event ID.
+func (v *WebhookVerifier) check(header string, body []byte, now time.Time) (string, error) {
+	if header == "" {
+		return "", ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			timestamp = val
+		case "v1":
+			if sig, err := hex.DecodeString(val); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return "", ErrInvalidSignature
+	}
+
+	if !v.matches(timestamp, body, signatures) {
+		return "", ErrInvalidSignature
+	}
+
+	if skew := now.Sub(time.Unix(ts, 0)); skew > v.tolerance || skew < -v.tolerance {
+		return "", ErrTimestampOutOfRange
+	}
+
+	var event struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" {
+		return "", ErrMissingEventID
+	}
+
+	return event.ID, nil
+}
+
+func (v *WebhookVerifier) matches(timestamp string, body []byte, signatures [][]byte) bool {
+	for _, secret := range v.secrets {
+		expected := SignWebhook(secret, timestamp, body)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reserve records eventID, failing if it was already accepted within the
+// tolerance window. Older IDs are forgotten: their timestamps are rejected
+// anyway. They are pruned at most once per webhookPruneInterval.
+func (v *WebhookVerifier) reserve(eventID string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(v.lastPrune) >= webhookPruneInterval {
+		for id, at := range v.seen {
+			if now.Sub(at) > 2*v.tolerance {
+				delete(v.seen, id)
+			}
+		}
+		v.lastPrune = now
+	}
+
+	if at, ok := v.seen[eventID]; ok && now.Sub(at) <= 2*v.tolerance {
+		return ErrDuplicateEvent
+	}
+	v.seen[eventID] = now
+	return nil
+}
+
+func (v *WebhookVerifier) release(eventID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.seen, eventID)
+}
+
+// SignWebhook computes the v1 signature for a webhook body.
+func SignWebhook(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
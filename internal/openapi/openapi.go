@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Security scheme names used in generated documents.
+const (
+	SchemeBearer           = "bearerAuth"
+	SchemeAPIKey           = "apiKey"
+	SchemeLegacyUserID     = "legacyUserId"
+	SchemeMutualTLS        = "mutualTLS"
+	SchemeWebhookSignature = "webhookSignature"
+)
+
+// USER-042: OpenAPI document generated from the gateway route table
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Permission  string                `json:"x-permission,omitempty"`
+}
+
+type Parameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *schema.Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds either an inline schema or a Ref.
+type MediaType struct {
+	Schema interface{} `json:"schema"`
+}
+
+// Ref points at a schema under components.
+type Ref struct {
+	Ref string `json:"$ref"`
+}
+
+type Components struct {
+	Schemas         map[string]*schema.Schema `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Route is the route metadata a document is generated from. Security lists
+// alternative scheme names; an empty list means the route is public.
+type Route struct {
+	Method         string
+	Path           string
+	Description    string
+	Security       []string
+	Permission     string
+	Deprecated     bool
+	RequestSchema  string
+	ResponseSchema string
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Generate builds a document for routes. Request and response schemas are
+// looked up in schemas by name; unknown names are left out.
+func Generate(info Info, routes []Route, schemas *schema.Registry) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         map[string]*schema.Schema{"ValidationError": validationErrorSchema()},
+			SecuritySchemes: securitySchemes(),
+		},
+	}
+
+	for _, rt := range routes {
+		path := pathParam.ReplaceAllString(rt.Path, "{$1}")
+		op := &Operation{
+			OperationID: operationID(rt.Method, path),
+			Tags:        tags(path),
+			Description: rt.Description,
+			Deprecated:  rt.Deprecated,
+			Permission:  rt.Permission,
+			Responses:   make(map[string]Response),
+		}
+
+		for _, m := range pathParam.FindAllStringSubmatch(rt.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &schema.Schema{Type: schema.Types{"string"}},
+			})
+		}
+
+		if rt.RequestSchema != "" {
+			if s, ok := schemas.Get(rt.RequestSchema); ok {
+				doc.Components.Schemas[rt.RequestSchema] = s
+				op.RequestBody = &RequestBody{
+					Required: true,
+					Content:  jsonContent(ref(rt.RequestSchema)),
+				}
+				op.Responses["400"] = Response{
+					Description: "Request body failed validation",
+					Content:     jsonContent(ref("ValidationError")),
+				}
+			}
+		}
+
+		op.Responses["default"] = Response{Description: "Upstream service response"}
+		if rt.ResponseSchema != "" {
+			if s, ok := schemas.Get(rt.ResponseSchema); ok {
+				doc.Components.Schemas[rt.ResponseSchema] = s
+				op.Responses["200"] = Response{Description: "OK", Content: jsonContent(ref(rt.ResponseSchema))}
+			}
+		}
+
+		for _, name := range rt.Security {
+			op.Security = append(op.Security, map[string][]string{name: {}})
+		}
+		if len(rt.Security) > 0 {
+			op.Responses["401"] = Response{Description: "Missing or invalid credentials"}
+		}
+		if rt.Permission != "" {
+			op.Description = strings.TrimSpace(op.Description + " Requires permission `" + rt.Permission + "`.")
+			op.Responses["403"] = Response{Description: "Missing permission: " + rt.Permission}
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return doc
+}
+
+func ref(name string) Ref {
+	return Ref{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(s interface{}) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+// operationID derives a stable ID such as "getApiV2UsersById".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By")
+			seg = strings.Trim(seg, "{}")
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		}) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// tags groups operations by resource: the segment after the API version,
+// or the first segment for unversioned paths.
+func tags(path string) []string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) >= 3 && segs[0] == "api" {
+		return []string{segs[2]}
+	}
+	if len(segs) > 0 && segs[0] != "" {
+		return []string{segs[0]}
+	}
+	return nil
+}
+
+func securitySchemes() map[string]SecurityScheme {
+	return map[string]SecurityScheme{
+		SchemeBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		SchemeAPIKey: {Type: "apiKey", In: "header", Name: "X-API-Key"},
+		SchemeLegacyUserID: {
+			Type:        "apiKey",
+			In:          "header",
+			Name:        "X-Legacy-User-Id",
+			Description: "Deprecated v1 fallback, accepted only from allowlisted networks.",
+		},
+		SchemeMutualTLS: {Type: "mutualTLS"},
+		SchemeWebhookSignature: {
+			Type:        "apiKey",
+			In:          "header",
+			Name:        "X-Webhook-Signature",
+			Description: `HMAC-SHA256 signature: t=<unix>,v1=<hex>. X-Stripe-Signature is also accepted.`,
+		},
+	}
+}
+
+func validationErrorSchema() *schema.Schema {
+	str := &schema.Schema{Type: schema.Types{"string"}}
+	return &schema.Schema{
+		Type:     schema.Types{"object"},
+		Required: []string{"error", "message", "violations"},
+		Properties: map[string]*schema.Schema{
+			"error":   str,
+			"message": str,
+			"violations": {
+				Type: schema.Types{"array"},
+				Items: &schema.Schema{
+					Type:     schema.Types{"object"},
+					Required: []string{"path", "message"},
+					Properties: map[string]*schema.Schema{
+						"path":    str,
+						"message": str,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/openapi"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	reg, _ := schema.LoadDir("")
+	body, _ := schema.Parse([]byte(`{"type":"object","required":["amount"]}`))
+	reg.Add("payment_create", body)
+
+	doc := openapi.Generate(openapi.Info{Title: "test", Version: "1"}, []openapi.Route{
+		{Method: "GET", Path: "/api/v2/orders/{id}", Description: "One order.", Security: []string{openapi.SchemeBearer}, Permission: "orders:read"},
+		{Method: "POST", Path: "/api/v2/payments", Security: []string{openapi.SchemeBearer}, RequestSchema: "payment_create"},
+		{Method: "GET", Path: "/api/v1/users/{id}", Deprecated: true},
+		{Method: "POST", Path: "/api/v2/orders", RequestSchema: "missing"},
+	}, reg)
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("expected openapi 3.1.0, got %s", doc.OpenAPI)
+	}
+
+	get := doc.Paths["/api/v2/orders/{id}"]["get"]
+	if get == nil {
+		t.Fatal("expected GET /api/v2/orders/{id}")
+	}
+	if get.OperationID != "getApiV2OrdersById" {
+		t.Errorf("unexpected operationId %s", get.OperationID)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Errorf("expected id path parameter, got %+v", get.Parameters)
+	}
+	if _, ok := get.Responses["403"]; !ok || get.Permission != "orders:read" {
+		t.Error("expected permission to be documented")
+	}
+	if get.Description != "One order. Requires permission `orders:read`." {
+		t.Errorf("unexpected description %q", get.Description)
+	}
+
+	post := doc.Paths["/api/v2/payments"]["post"]
+	if post.RequestBody == nil {
+		t.Fatal("expected request body for payment_create")
+	}
+	if _, ok := doc.Components.Schemas["payment_create"]; !ok {
+		t.Error("expected payment_create in components")
+	}
+	if _, ok := post.Responses["400"]; !ok {
+		t.Error("expected validation error response")
+	}
+
+	if !doc.Paths["/api/v1/users/{id}"]["get"].Deprecated {
+		t.Error("expected v1 route to be deprecated")
+	}
+	if doc.Paths["/api/v2/orders"]["post"].RequestBody != nil {
+		t.Error("expected unknown schema to be omitted")
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to encode document: %v", err)
+	}
+	if !strings.Contains(string(data), `"$ref":"#/components/schemas/payment_create"`) {
+		t.Error("expected request body to reference components")
+	}
+}
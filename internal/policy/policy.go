@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Policy maps roles to the permissions they grant. Permissions are
+// "resource:action" strings; a grant of "*" or "resource:*" matches any
+// permission it prefixes.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// Default returns the built-in policy used when no policy file is present.
+func Default() *Policy {
+	return &Policy{
+		Roles: map[string][]string{
+			"admin": {"*"},
+			"support": {
+				"users:read", "users:list", "users:any", "users:impersonate",
+				"orders:read", "orders:any",
+				"payments:read", "payments:any",
+				"notifications:read",
+			},
+			"customer": {
+				"users:read", "users:update",
+				"orders:read", "orders:create", "orders:update_status", "orders:cancel",
+				"payments:read", "payments:create", "payments:refund",
+				"notifications:read", "notifications:send",
+			},
+			"service": {
+				"users:read", "users:any",
+				"orders:read", "orders:create", "orders:any",
+				"payments:read", "payments:any",
+				"notifications:send",
+				"ops:read",
+			},
+		},
+	}
+}
+
+// Load reads a policy from a JSON file, falling back to Default when the
+// file does not exist.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if p.Roles == nil {
+		p.Roles = map[string][]string{}
+	}
+
+	return &p, nil
+}
+
+// RoleGrants reports whether role grants perm.
+func (p *Policy) RoleGrants(role, perm string) bool {
+	return anyMatch(p.Roles[role], perm)
+}
+
+// Allowed reports whether a caller with role and scopes holds perm. A nil
+// scopes slice means the credential is unrestricted; otherwise the scopes
+// must also cover perm.
+func (p *Policy) Allowed(role string, scopes []string, perm string) bool {
+	if !p.RoleGrants(role, perm) {
+		return false
+	}
+	if scopes == nil {
+		return true
+	}
+	return anyMatch(scopes, perm)
+}
+
+func anyMatch(grants []string, perm string) bool {
+	for _, g := range grants {
+		if Matches(g, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a single grant covers perm.
+func Matches(grant, perm string) bool {
+	if grant == "*" || grant == perm {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(grant, ":*"); ok {
+		return strings.HasPrefix(perm, prefix+":")
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/policy"
+)
+
+func TestPolicy_Allowed(t *testing.T) {
+	p := &policy.Policy{
+		Roles: map[string][]string{
+			"admin":    {"*"},
+			"customer": {"orders:read", "orders:create"},
+			"service":  {"orders:*"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		role   string
+		scopes []string
+		perm   string
+		want   bool
+	}{
+		{"admin wildcard", "admin", nil, "payments:refund", true},
+		{"customer granted", "customer", nil, "orders:read", true},
+		{"customer denied", "customer", nil, "payments:refund", false},
+		{"resource wildcard", "service", nil, "orders:cancel", true},
+		{"scope restricts", "service", []string{"orders:read"}, "orders:cancel", false},
+		{"scope allows", "service", []string{"orders:read"}, "orders:read", true},
+		{"empty scopes deny", "admin", []string{}, "orders:read", false},
+		{"unknown role", "guest", nil, "orders:read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allowed(tt.role, tt.scopes, tt.perm); got != tt.want {
+				t.Errorf("Allowed(%s, %v, %s) = %v, want %v", tt.role, tt.scopes, tt.perm, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
@@ -15,38 +17,80 @@ import (
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 )
 
+// USER-037: HeaderDeadline tells upstreams how many milliseconds remain in
+// the gateway's budget for the call, so they can abandon work early.
+const HeaderDeadline = "X-Acme-Deadline-Ms"
+
+// ErrDeadlineExceeded is returned when the request's budget runs out before
+// or during an upstream call.
+var ErrDeadlineExceeded = errors.New("upstream deadline exceeded")
+
+// upstream is a backend service and the longest a single call to it may take.
+type upstream struct {
+	baseURL string
+	timeout time.Duration
+}
+
 type Client struct {
-	httpClient *http.Client
-	config     *config.Config
+	httpClient    *http.Client
+	config        *config.Config
+	users         upstream
+	orders        upstream
+	payments      upstream
+	notifications upstream
 }
 
 func NewClient(cfg *config.Config) *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.RequestTimeout) * time.Second,
-		},
-		config: cfg,
+		// Budgets are enforced per call through the request context, so the
+		// HTTP client carries no overall timeout of its own.
+		httpClient:    &http.Client{},
+		config:        cfg,
+		users:         newUpstream(cfg.UsersServiceURL, cfg.UsersServiceTimeout, cfg),
+		orders:        newUpstream(cfg.OrdersServiceURL, cfg.OrdersServiceTimeout, cfg),
+		payments:      newUpstream(cfg.PaymentsServiceURL, cfg.PaymentsServiceTimeout, cfg),
+		notifications: newUpstream(cfg.NotificationsServiceURL, cfg.NotificationsTimeout, cfg),
 	}
 }
 
+// newUpstream falls back to REQUEST_TIMEOUT_SECONDS when no per-service
+// timeout is configured.
+func newUpstream(baseURL string, timeoutSeconds int, cfg *config.Config) upstream {
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = cfg.RequestTimeout
+	}
+	return upstream{baseURL: baseURL, timeout: time.Duration(timeoutSeconds) * time.Second}
+}
+
 func (c *Client) ProxyToUsers(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
-	return c.proxy(ctx, c.config.UsersServiceURL, method, path, body)
+	return c.proxy(ctx, c.users, method, path, body)
 }
 
 func (c *Client) ProxyToOrders(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
-	return c.proxy(ctx, c.config.OrdersServiceURL, method, path, body)
+	return c.proxy(ctx, c.orders, method, path, body)
 }
 
 func (c *Client) ProxyToPayments(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
-	return c.proxy(ctx, c.config.PaymentsServiceURL, method, path, body)
+	return c.proxy(ctx, c.payments, method, path, body)
 }
 
 func (c *Client) ProxyToNotifications(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
-	return c.proxy(ctx, c.config.NotificationsServiceURL, method, path, body)
+	return c.proxy(ctx, c.notifications, method, path, body)
 }
 
-func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body interface{}) ([]byte, int, error) {
-	url := baseURL + path
+func (c *Client) proxy(ctx context.Context, svc upstream, method, path string, body interface{}) ([]byte, int, error) {
+	url := svc.baseURL + path
+
+	// The call gets the shorter of the service timeout and whatever is left
+	// of the route's budget.
+	ctx, cancel := context.WithTimeout(ctx, svc.timeout)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil, 0, fmt.Errorf("%w: %s %s", ErrDeadlineExceeded, method, url)
+	}
 
 	var bodyReader io.Reader
 	if body != nil {
@@ -63,6 +107,7 @@ func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body i
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeadline, strconv.FormatInt(remaining.Milliseconds(), 10))
 
 	requestID := middleware.GetRequestIDFromContext(ctx)
 	if requestID != "" {
@@ -75,6 +120,10 @@ func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body i
 		req.Header.Set("X-Legacy-User-Id", userID)
 	}
 
+	if actorID := middleware.GetActorIDFromContext(ctx); actorID != "" {
+		req.Header.Set("X-Acme-Actor-Id", actorID)
+	}
+
 	logging.Info("Proxying request", logging.Fields{
 		"method":     method,
 		"url":        url,
@@ -83,6 +132,13 @@ func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body i
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logging.Warn("Upstream deadline exceeded", logging.Fields{
+				"method": method,
+				"url":    url,
+			})
+			return nil, 0, fmt.Errorf("%w: %s %s", ErrDeadlineExceeded, method, url)
+		}
 		logging.Error("Proxy request failed", logging.Fields{
 			"method": method,
 			"url":    url,
@@ -94,6 +150,9 @@ func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body i
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, resp.StatusCode, fmt.Errorf("%w: %s %s", ErrDeadlineExceeded, method, url)
+		}
 		return nil, resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
 	}
 
@@ -105,7 +164,7 @@ func (c *Client) proxy(ctx context.Context, baseURL, method, path string, body i
 // TODO(TEAM-API): Remove after v1 API deprecation
 func (c *Client) ProxyToUsersLegacy(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
 	log.Printf("Legacy proxy to users service: %s %s", method, path)
-	return c.proxy(ctx, c.config.UsersServiceURL, method, "/v1"+path, body)
+	return c.proxy(ctx, c.users, method, "/v1"+path, body)
 }
 
 // ProxyToOrdersLegacy proxies requests using the old v1 API format.
@@ -113,5 +172,45 @@ func (c *Client) ProxyToUsersLegacy(ctx context.Context, method, path string, bo
 // TODO(TEAM-API): Remove after v1 API deprecation
 func (c *Client) ProxyToOrdersLegacy(ctx context.Context, method, path string, body interface{}) ([]byte, int, error) {
 	log.Printf("Legacy proxy to orders service: %s %s", method, path)
-	return c.proxy(ctx, c.config.OrdersServiceURL, method, "/v1"+path, body)
+	return c.proxy(ctx, c.orders, method, "/v1"+path, body)
+}
+
+// USER-029: Owner lookups for ownership enforcement
+
+// OrderOwner returns the user ID that owns an order.
+func (c *Client) OrderOwner(ctx context.Context, orderID string) (string, error) {
+	return c.owner(ctx, c.orders, "/api/v2/orders/"+orderID)
+}
+
+// PaymentOwner returns the user ID that owns a payment.
+func (c *Client) PaymentOwner(ctx context.Context, paymentID string) (string, error) {
+	return c.owner(ctx, c.payments, "/api/v2/payments/"+paymentID)
+}
+
+func (c *Client) owner(ctx context.Context, svc upstream, path string) (string, error) {
+	body, status, err := c.proxy(ctx, svc, "GET", path, nil)
+	if errors.Is(err, ErrDeadlineExceeded) {
+		return "", fmt.Errorf("%w: %w", middleware.ErrLookupDeadlineExceeded, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusNotFound {
+		return "", middleware.ErrResourceNotFound
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("owner lookup returned status %d", status)
+	}
+
+	var resource struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal(body, &resource); err != nil {
+		return "", fmt.Errorf("failed to decode owner lookup: %w", err)
+	}
+	if resource.UserID == "" {
+		return "", errors.New("owner lookup response has no user_id")
+	}
+
+	return resource.UserID, nil
 }
@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+)
+
+// USER-035: Operational and admin endpoints live on a separate listener
+// AdminTable returns the routes served on the admin listener. Health and
+// readiness stay unauthenticated for probes; everything else requires the
+// admin token.
+func AdminTable(h *handlers.Handlers, cfg *config.Config) []Route {
+	return []Route{
+		{Method: "GET", Path: "/health", Handler: h.Health.Health},
+		{Method: "GET", Path: "/ready", Handler: h.Health.Ready},
+		{Method: "GET", Path: "/metrics", Handler: h.Health.Metrics, Auth: AuthAdmin},
+
+		{Method: "GET", Path: "/debug/pprof/", Handler: pprof.Index, Auth: AuthAdmin},
+		{Method: "GET", Path: "/debug/pprof/cmdline", Handler: pprof.Cmdline, Auth: AuthAdmin},
+		{Method: "GET", Path: "/debug/pprof/profile", Handler: pprof.Profile, Auth: AuthAdmin},
+		{Method: "GET", Path: "/debug/pprof/symbol", Handler: pprof.Symbol, Auth: AuthAdmin},
+		{Method: "GET", Path: "/debug/pprof/trace", Handler: pprof.Trace, Auth: AuthAdmin},
+
+		{Method: "GET", Path: "/admin/config", Handler: h.Admin.Config, Auth: AuthAdmin},
+
+		// USER-026: API key administration
+		{Method: "POST", Path: "/admin/api-keys", Handler: h.APIKeys.CreateKey, Auth: AuthAdmin, Permission: "apikeys:manage"},
+		{Method: "GET", Path: "/admin/api-keys", Handler: h.APIKeys.ListKeys, Auth: AuthAdmin, Permission: "apikeys:manage"},
+		{Method: "DELETE", Path: "/admin/api-keys/{id}", Handler: h.APIKeys.RevokeKey, Auth: AuthAdmin, Permission: "apikeys:manage"},
+
+		// USER-030: Audited, time-boxed legacy auth bypass (SEC-1002)
+		{Method: "GET", Path: "/admin/bypasses", Handler: h.Bypass.ListBypasses, Auth: AuthAdmin, Permission: "bypasses:read"},
+
+		// USER-050: Per-caller usage of deprecated v1 routes, including the
+		// USER-031 legacy header fallback
+		{Method: "GET", Path: "/admin/v1-usage", Handler: h.V1Usage.List, Auth: AuthAdmin, Permission: "legacy:read"},
+		{Method: "GET", Path: "/admin/v1-usage/export", Handler: h.V1Usage.Export, Auth: AuthAdmin, Permission: "legacy:read"},
+
+		// USER-043: Upstream response contract drift
+		{Method: "GET", Path: "/admin/contract-drift", Handler: h.Contracts.Drift, Auth: AuthAdmin, Permission: "contracts:read"},
+
+		// USER-040: Webhook dead-letter store
+		{Method: "GET", Path: "/admin/webhooks/dead", Handler: h.Webhooks.ListDead, Auth: AuthAdmin, Permission: "webhooks:manage"},
+		{Method: "POST", Path: "/admin/webhooks/dead/{id}/replay", Handler: h.Webhooks.ReplayDead, Auth: AuthAdmin, Permission: "webhooks:manage"},
+	}
+}
+
+// SetupAdmin builds the handler for the admin listener.
+func SetupAdmin(h *handlers.Handlers, mw Middleware, cfg *config.Config) http.Handler {
+	mux := http.NewServeMux()
+
+	correlationMW := middleware.NewCorrelationMiddleware()
+	loggingMW := middleware.NewLoggingMiddleware()
+
+	// Request budgets are for customer traffic; pprof profiles and traces
+	// run for as long as the operator asks.
+	mw.Timeout = nil
+
+	adminTable := AdminTable(h, cfg)
+	for _, rt := range adminTable {
+		mux.Handle(rt.Pattern(), wrap(rt, mw, cfg))
+	}
+
+	listing := listRoutes(map[string][]Route{
+		"public": Table(h, cfg),
+		"admin":  adminTable,
+	})
+	mux.Handle("GET /admin/routes", wrap(Route{Handler: listing, Auth: AuthAdmin}, mw, cfg))
+
+	var handler http.Handler = mux
+	handler = loggingMW.Log(handler)
+	handler = correlationMW.AddRequestID(handler)
+
+	return handler
+}
+
+type routeInfo struct {
+	Listener   string `json:"listener"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Auth       string `json:"auth"`
+	Permission string `json:"permission,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+}
+
+func listRoutes(tables map[string][]Route) http.HandlerFunc {
+	var infos []routeInfo
+	for _, listener := range []string{"public", "admin"} {
+		for _, rt := range tables[listener] {
+			infos = append(infos, routeInfo{
+				Listener:   listener,
+				Method:     rt.Method,
+				Path:       rt.Path,
+				Auth:       rt.Auth.String(),
+				Permission: rt.Permission,
+				Owner:      rt.Owner,
+			})
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"routes": infos})
+	}
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/openapi"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+)
+
+// OpenAPI describes the public route table. Bypass routes are internal
+// tooling and are left out.
+func OpenAPI(h *handlers.Handlers, cfg *config.Config, schemas *schema.Registry) *openapi.Document {
+	var ops []openapi.Route
+	for _, rt := range Table(h, cfg) {
+		if rt.Auth == AuthBypass {
+			continue
+		}
+		ops = append(ops, openapi.Route{
+			Method:         rt.Method,
+			Path:           rt.Path,
+			Description:    rt.Description,
+			Security:       securitySchemes(rt.Auth),
+			Permission:     rt.Permission,
+			Deprecated:     rt.Deprecation != nil,
+			RequestSchema:  rt.Schema,
+			ResponseSchema: rt.ResponseSchema,
+		})
+	}
+
+	return openapi.Generate(openapi.Info{
+		Title:       "AcmeShop Gateway API",
+		Version:     "2.0",
+		Description: "Generated from the gateway route table.",
+	}, ops, schemas)
+}
+
+func securitySchemes(auth AuthMode) []string {
+	switch auth {
+	case AuthJWT:
+		return []string{openapi.SchemeBearer, openapi.SchemeAPIKey}
+	case AuthLegacy:
+		return []string{openapi.SchemeBearer, openapi.SchemeAPIKey, openapi.SchemeLegacyUserID}
+	case AuthMTLS:
+		return []string{openapi.SchemeMutualTLS}
+	case AuthWebhook:
+		return []string{openapi.SchemeWebhookSignature}
+	}
+	return nil
+}
+
+// serveOpenAPI encodes doc once and serves the same bytes for every request.
+func serveOpenAPI(doc *openapi.Document) http.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
@@ -1,68 +1,331 @@
 package routes
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/handlers"
 	"github.com/tm-acme-shop/acme-shop-gateway/internal/middleware"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
 )
 
-func Setup(h *handlers.Handlers, authMW *middleware.AuthMiddleware, cfg *config.Config) http.Handler {
-	mux := http.NewServeMux()
+// AuthMode selects how a route authenticates callers.
+type AuthMode int
 
-	correlationMW := middleware.NewCorrelationMiddleware()
-	loggingMW := middleware.NewLoggingMiddleware()
-	rateLimitMW := middleware.NewRateLimitMiddleware(cfg)
+const (
+	AuthNone AuthMode = iota
+	AuthJWT
+	AuthLegacy
+	AuthBypass
+	AuthMTLS
+	AuthAdmin
+	AuthWebhook
+)
+
+func (a AuthMode) String() string {
+	switch a {
+	case AuthJWT:
+		return "jwt"
+	case AuthLegacy:
+		return "legacy"
+	case AuthBypass:
+		return "bypass"
+	case AuthMTLS:
+		return "mtls"
+	case AuthAdmin:
+		return "admin"
+	case AuthWebhook:
+		return "webhook"
+	}
+	return "none"
+}
 
-	mux.HandleFunc("GET /health", h.Health.Health)
-	mux.HandleFunc("GET /ready", h.Health.Ready)
-	mux.HandleFunc("GET /metrics", h.Health.Metrics)
+// Route describes a single gateway endpoint. Permission, when set, is
+// checked against the authorization policy after authentication. Owner
+// names the resource whose {id} must belong to the caller. Timeout
+// overrides REQUEST_TIMEOUT_SECONDS as the route's total budget. Schema
+// names the JSON Schema in SCHEMA_DIR that request bodies must satisfy, and
+// ResponseSchema the one documenting successful responses. Deprecation
+// marks the route deprecated and drives its Deprecation and Sunset headers.
+type Route struct {
+	Method     string
+	Path       string
+	Handler    http.HandlerFunc
+	Auth       AuthMode
+	Permission string
+	Owner      string
+	Timeout    time.Duration
+	Schema     string
 
-	mux.HandleFunc("POST /auth/login", h.Auth.Login)
-	mux.HandleFunc("POST /auth/refresh", h.Auth.Refresh)
-	mux.HandleFunc("POST /auth/logout", h.Auth.Logout)
+	Description    string
+	ResponseSchema string
+	Deprecation    *middleware.Deprecation
+}
+
+// Pattern returns the ServeMux pattern for the route.
+func (rt Route) Pattern() string {
+	return rt.Method + " " + rt.Path
+}
+
+// Middleware bundles the per-route middleware applied from the route table.
+type Middleware struct {
+	Auth        *middleware.AuthMiddleware
+	Ownership   *middleware.OwnershipMiddleware
+	Bypass      *middleware.BypassRegistry
+	MTLS        *middleware.MTLSIdentities
+	Admin       *middleware.AdminAuth
+	RateLimit   *middleware.RateLimitMiddleware
+	Timeout     *middleware.TimeoutMiddleware
+	Idempotency *middleware.IdempotencyMiddleware
+	Webhook     *middleware.WebhookVerifier
+	Validation  *middleware.ValidationMiddleware
+	Responses   *middleware.ResponseValidationMiddleware
+	Deprecation *middleware.DeprecationMiddleware
+	Usage       *middleware.UsageMiddleware
+}
+
+// graphQLDescription documents the /graphql cost model in the OpenAPI
+// document.
+const graphQLDescription = "GraphQL queries over the users, orders, payments and notifications services. " +
+	"Upstream lookups are not batched: a nested entity in a list costs one upstream call per element. " +
+	"Query cost is one per field plus 5 per upstream lookup, with list subfields counted 10 times; " +
+	"queries above GRAPHQL_MAX_COMPLEXITY are rejected with 400 before any upstream call."
+
+// Table returns the gateway's route table for the given configuration.
+func Table(h *handlers.Handlers, cfg *config.Config) []Route {
+	table := []Route{
+		{Method: "POST", Path: "/auth/login", Handler: h.Auth.Login},
+		{Method: "POST", Path: "/auth/refresh", Handler: h.Auth.Refresh},
+		{Method: "POST", Path: "/auth/logout", Handler: h.Auth.Logout},
+
+		// USER-027: OAuth2 client_credentials for machine clients
+		{Method: "POST", Path: "/oauth/token", Handler: h.OAuth.Token},
+
+		// USER-032: Support impersonation token exchange
+		{Method: "POST", Path: "/auth/impersonate", Handler: h.Auth.Impersonate, Auth: AuthJWT, Permission: "users:impersonate"},
+	}
 
 	if cfg.EnableNewAuth {
-		mux.HandleFunc("POST /auth/login/legacy", h.Auth.LoginLegacy)
+		table = append(table, Route{Method: "POST", Path: "/auth/login/legacy", Handler: h.Auth.LoginLegacy})
 	}
 
 	// API-150: v2 API routes with new JWT auth (2023-04)
-	mux.Handle("GET /api/v2/users/{id}", authMW.Authenticate(http.HandlerFunc(h.Users.GetUser)))
-	mux.Handle("POST /api/v2/users", authMW.Authenticate(authMW.RequireRole("admin")(http.HandlerFunc(h.Users.CreateUser))))
-	mux.Handle("PUT /api/v2/users/{id}", authMW.Authenticate(http.HandlerFunc(h.Users.UpdateUser)))
-	mux.Handle("DELETE /api/v2/users/{id}", authMW.Authenticate(authMW.RequireRole("admin")(http.HandlerFunc(h.Users.DeleteUser))))
-	mux.Handle("GET /api/v2/users", authMW.Authenticate(authMW.RequireRole("admin")(http.HandlerFunc(h.Users.ListUsers))))
-
-	mux.Handle("GET /api/v2/orders/{id}", authMW.Authenticate(http.HandlerFunc(h.Orders.GetOrder)))
-	mux.Handle("POST /api/v2/orders", authMW.Authenticate(http.HandlerFunc(h.Orders.CreateOrder)))
-	mux.Handle("PATCH /api/v2/orders/{id}/status", authMW.Authenticate(http.HandlerFunc(h.Orders.UpdateOrderStatus)))
-	mux.Handle("GET /api/v2/orders", authMW.Authenticate(http.HandlerFunc(h.Orders.ListUserOrders)))
-	mux.Handle("POST /api/v2/orders/{id}/cancel", authMW.Authenticate(http.HandlerFunc(h.Orders.CancelOrder)))
-
-	mux.Handle("POST /api/v2/payments", authMW.Authenticate(http.HandlerFunc(h.Payments.ProcessPayment)))
-	mux.Handle("GET /api/v2/payments/{id}", authMW.Authenticate(http.HandlerFunc(h.Payments.GetPayment)))
-	mux.Handle("POST /api/v2/payments/{id}/refund", authMW.Authenticate(http.HandlerFunc(h.Payments.RefundPayment)))
-	mux.HandleFunc("POST /api/v2/payments/webhook", h.Payments.HandleWebhook)
-
-	mux.Handle("POST /api/v2/notifications", authMW.Authenticate(http.HandlerFunc(h.Notifications.SendNotification)))
-	mux.Handle("GET /api/v2/notifications/{id}", authMW.Authenticate(http.HandlerFunc(h.Notifications.GetNotification)))
-	mux.Handle("POST /api/v2/notifications/email", authMW.Authenticate(http.HandlerFunc(h.Notifications.SendEmail)))
-	mux.Handle("POST /api/v2/notifications/sms", authMW.Authenticate(http.HandlerFunc(h.Notifications.SendSMS)))
+	table = append(table,
+		Route{Method: "GET", Path: "/api/v2/users/{id}", Handler: h.Users.GetUser, Auth: AuthJWT, Permission: "users:read", Owner: "users", ResponseSchema: "user"},
+		Route{Method: "POST", Path: "/api/v2/users", Handler: h.Users.CreateUser, Auth: AuthJWT, Permission: "users:create", Schema: "user_create"},
+		Route{Method: "PUT", Path: "/api/v2/users/{id}", Handler: h.Users.UpdateUser, Auth: AuthJWT, Permission: "users:update", Owner: "users"},
+		Route{Method: "DELETE", Path: "/api/v2/users/{id}", Handler: h.Users.DeleteUser, Auth: AuthJWT, Permission: "users:delete"},
+		Route{Method: "GET", Path: "/api/v2/users", Handler: h.Users.ListUsers, Auth: AuthJWT, Permission: "users:list"},
+
+		Route{Method: "GET", Path: "/api/v2/orders/{id}", Handler: h.Orders.GetOrder, Auth: AuthJWT, Permission: "orders:read", Owner: "orders", ResponseSchema: "order"},
+		Route{Method: "POST", Path: "/api/v2/orders", Handler: h.Orders.CreateOrder, Auth: AuthJWT, Permission: "orders:create", Schema: "order_create"},
+		Route{Method: "PATCH", Path: "/api/v2/orders/{id}/status", Handler: h.Orders.UpdateOrderStatus, Auth: AuthJWT, Permission: "orders:update_status", Owner: "orders"},
+		Route{Method: "GET", Path: "/api/v2/orders", Handler: h.Orders.ListUserOrders, Auth: AuthJWT, Permission: "orders:read"},
+		Route{Method: "POST", Path: "/api/v2/orders/{id}/cancel", Handler: h.Orders.CancelOrder, Auth: AuthJWT, Permission: "orders:cancel", Owner: "orders"},
+
+		Route{Method: "POST", Path: "/api/v2/payments", Handler: h.Payments.ProcessPayment, Auth: AuthJWT, Permission: "payments:create", Schema: "payment_create"},
+		Route{Method: "GET", Path: "/api/v2/payments/{id}", Handler: h.Payments.GetPayment, Auth: AuthJWT, Permission: "payments:read", Owner: "payments", ResponseSchema: "payment"},
+		Route{Method: "POST", Path: "/api/v2/payments/{id}/refund", Handler: h.Payments.RefundPayment, Auth: AuthJWT, Permission: "payments:refund", Owner: "payments", Schema: "payment_refund"},
+		// USER-039: Signed by the payment provider instead of a user credential
+		Route{Method: "POST", Path: "/api/v2/payments/webhook", Handler: h.Payments.HandleWebhook, Auth: AuthWebhook},
+
+		// USER-044: Order detail screen in a single round trip
+		Route{Method: "GET", Path: "/api/v2/views/orders/{id}", Handler: h.Views.OrderView, Auth: AuthJWT, Permission: "orders:read", Owner: "orders"},
+
+		Route{Method: "POST", Path: "/api/v2/notifications", Handler: h.Notifications.SendNotification, Auth: AuthJWT, Permission: "notifications:send"},
+		Route{Method: "GET", Path: "/api/v2/notifications/{id}", Handler: h.Notifications.GetNotification, Auth: AuthJWT, Permission: "notifications:read"},
+		Route{Method: "POST", Path: "/api/v2/notifications/email", Handler: h.Notifications.SendEmail, Auth: AuthJWT, Permission: "notifications:send"},
+		Route{Method: "POST", Path: "/api/v2/notifications/sms", Handler: h.Notifications.SendSMS, Auth: AuthJWT, Permission: "notifications:send"},
+
+		// USER-046: Field-level authorization happens in the executor
+		Route{Method: "POST", Path: "/graphql", Handler: h.GraphQL.Serve, Auth: AuthJWT, Description: graphQLDescription},
+		Route{Method: "GET", Path: "/graphql", Handler: h.GraphQL.Serve, Auth: AuthJWT, Description: graphQLDescription},
+
+		// USER-047: Each sub-request is authorized on its own route
+		Route{Method: "POST", Path: handlers.BatchPath, Handler: h.Batch.Serve, Auth: AuthJWT},
+
+		// USER-030: Audited, time-boxed legacy auth bypass (SEC-1002)
+		Route{Method: "GET", Path: "/internal/legacy/health", Handler: h.Health.Health, Auth: AuthBypass},
+		Route{Method: "GET", Path: "/internal/legacy/metrics", Handler: h.Health.Metrics, Auth: AuthBypass},
+
+		// USER-033: mTLS-authenticated replacements for the legacy bypass paths
+		Route{Method: "GET", Path: "/internal/health", Handler: h.Health.Health, Auth: AuthMTLS},
+		Route{Method: "GET", Path: "/internal/metrics", Handler: h.Health.Metrics, Auth: AuthMTLS, Permission: "ops:read"},
+	)
+
+	// USER-045: Composite endpoints from COMPOSITES_PATH
+	for _, ep := range h.Composites.Endpoints() {
+		table = append(table, Route{
+			Method:     ep.Method,
+			Path:       ep.Path,
+			Handler:    h.Composites.Serve(ep),
+			Auth:       AuthJWT,
+			Permission: ep.Permission,
+			Owner:      ep.Owner,
+			Timeout:    ep.Timeout(),
+		})
+	}
 
 	// API-100: Initial v1 API routes (2022-04)
 	if cfg.EnableV1API {
-		mux.Handle("GET /api/v1/users/{id}", authMW.AuthenticateLegacy(http.HandlerFunc(h.Users.GetUserV1)))
-		mux.Handle("POST /api/v1/users", authMW.AuthenticateLegacy(http.HandlerFunc(h.Users.CreateUserV1)))
-		mux.Handle("GET /api/v1/orders/{id}", authMW.AuthenticateLegacy(http.HandlerFunc(h.Orders.GetOrderV1)))
-		mux.Handle("POST /api/v1/payments", authMW.AuthenticateLegacy(http.HandlerFunc(h.Payments.ProcessPaymentV1)))
-		mux.Handle("POST /api/v1/email/send", authMW.AuthenticateLegacy(http.HandlerFunc(h.Notifications.SendEmailLegacy)))
+		v1 := v1Deprecation(cfg)
+		start := len(table)
+		table = append(table,
+			Route{Method: "GET", Path: "/api/v1/users/{id}", Handler: h.Users.GetUserV1, Auth: AuthLegacy, Permission: "users:read", Owner: "users", Deprecation: v1},
+			Route{Method: "POST", Path: "/api/v1/users", Handler: h.Users.CreateUserV1, Auth: AuthLegacy, Permission: "users:create", Deprecation: v1},
+			Route{Method: "GET", Path: "/api/v1/orders/{id}", Handler: h.Orders.GetOrderV1, Auth: AuthLegacy, Permission: "orders:read", Owner: "orders", Deprecation: v1},
+			Route{Method: "POST", Path: "/api/v1/payments", Handler: h.Payments.ProcessPaymentV1, Auth: AuthLegacy, Permission: "payments:create", Deprecation: v1},
+			Route{Method: "POST", Path: "/api/v1/email/send", Handler: h.Notifications.SendEmailLegacy, Auth: AuthLegacy, Permission: "notifications:send", Deprecation: v1},
+		)
+
+		// USER-048: v1 routes with a rule in V1_TRANSLATIONS_PATH call v2
+		for i := start; i < len(table); i++ {
+			table[i].Handler = h.Translations.For(table[i].Pattern(), table[i].Handler)
+		}
+	}
+
+	return table
+}
+
+// Setup builds the gateway's handler. It fails if a route names a schema
+// that is not in schemas, rather than serving the route unvalidated.
+func Setup(h *handlers.Handlers, mw Middleware, cfg *config.Config, schemas *schema.Registry) (http.Handler, error) {
+	mux := http.NewServeMux()
+
+	correlationMW := middleware.NewCorrelationMiddleware()
+	loggingMW := middleware.NewLoggingMiddleware()
+
+	for _, rt := range Table(h, cfg) {
+		for _, name := range []string{rt.Schema, rt.ResponseSchema} {
+			if _, ok := schemas.Get(name); name != "" && !ok {
+				return nil, fmt.Errorf("route %s: schema %q not found in %s", rt.Pattern(), name, cfg.SchemaDir)
+			}
+		}
+		mux.Handle(rt.Pattern(), wrap(rt, mw, cfg))
 	}
 
+	// USER-042: Public API description, generated from the table above
+	mux.Handle("GET /openapi.json", serveOpenAPI(OpenAPI(h, cfg, schemas)))
+
 	var handler http.Handler = mux
 	handler = loggingMW.Log(handler)
-	handler = rateLimitMW.Limit(handler)
+	handler = mw.RateLimit.Limit(handler)
 	handler = correlationMW.AddRequestID(handler)
 
+	h.Batch.SetRouter(handler)
+
+	return handler, nil
+}
+
+// wrap applies the route's authentication and authorization middleware.
+func wrap(rt Route, mw Middleware, cfg *config.Config) http.Handler {
+	var handler http.Handler = rt.Handler
+
+	// Idempotent replays store the response as finally sent to the client.
+	if rt.ResponseSchema != "" && mw.Responses != nil {
+		handler = mw.Responses.Validate(rt.Pattern(), rt.ResponseSchema)(handler)
+	}
+
+	if mw.Idempotency != nil && idempotent(rt, cfg) {
+		handler = mw.Idempotency.Enforce(handler)
+	}
+
+	// Invalid bodies are rejected before an idempotency record is stored.
+	if rt.Schema != "" && mw.Validation != nil {
+		handler = mw.Validation.Validate(rt.Schema)(handler)
+	}
+
+	if rt.Owner != "" {
+		handler = mw.Ownership.RequireOwner(rt.Owner)(handler)
+	}
+
+	if rt.Permission != "" {
+		handler = mw.Auth.RequirePermission(rt.Permission)(handler)
+	}
+
+	if rt.Deprecation != nil && mw.Usage != nil {
+		handler = mw.Usage.Identify(handler)
+	}
+
+	switch rt.Auth {
+	case AuthJWT:
+		handler = mw.Auth.Authenticate(handler)
+	case AuthLegacy:
+		handler = mw.Auth.AuthenticateHybrid(rt.Pattern(), enforceJWT(rt, cfg))(handler)
+	case AuthBypass:
+		handler = mw.Bypass.Bypass(handler)
+	case AuthMTLS:
+		handler = mw.MTLS.Authenticate(handler)
+	case AuthAdmin:
+		handler = mw.Admin.Authenticate(handler)
+	case AuthWebhook:
+		handler = mw.Webhook.Verify(handler)
+	}
+
+	// The budget also covers ownership lookups made before the handler runs.
+	// Routes built without a TimeoutMiddleware get no deadline.
+	if mw.Timeout != nil {
+		handler = mw.Timeout.Deadline(mw.Timeout.Resolve(rt.Pattern(), rt.Timeout))(handler)
+	}
+
+	// Sunset routes are rejected before authentication, and every response,
+	// including auth failures, carries the deprecation headers.
+	if rt.Deprecation != nil && mw.Deprecation != nil {
+		handler = mw.Deprecation.Signal(rt.Pattern(), rt.Deprecation)(handler)
+	}
+
+	// Usage is counted outside everything else so rejected calls show up.
+	if rt.Deprecation != nil && mw.Usage != nil {
+		handler = mw.Usage.Track(rt.Pattern())(handler)
+	}
+
 	return handler
 }
+
+// USER-049: v1Deprecation builds the v1 routes' deprecation metadata.
+// Invalid dates are left unset.
+func v1Deprecation(cfg *config.Config) *middleware.Deprecation {
+	since, _ := middleware.ParseDate(cfg.V1DeprecationDate)
+	sunset, _ := middleware.ParseDate(cfg.V1SunsetDate)
+	return &middleware.Deprecation{Since: since, Sunset: sunset, Link: cfg.V1MigrationDocsURL}
+}
+
+// USER-037: WriteTimeout returns a server write timeout that outlasts the
+// longest route budget, so slow upstreams surface as 504s rather than the
+// connection being cut mid-response.
+func WriteTimeout(h *handlers.Handlers, mw Middleware, cfg *config.Config) time.Duration {
+	longest := time.Duration(cfg.RequestTimeout) * time.Second
+	for _, rt := range Table(h, cfg) {
+		if d := mw.Timeout.Resolve(rt.Pattern(), rt.Timeout); d > longest {
+			longest = d
+		}
+	}
+	return longest + 5*time.Second
+}
+
+// enforceJWT reports whether a legacy route has been switched off the
+// X-Legacy-User-Id fallback via V1_ENFORCE_JWT_ROUTES.
+func enforceJWT(rt Route, cfg *config.Config) bool {
+	for _, pattern := range cfg.V1EnforceJWTRoutes {
+		if pattern == "*" || pattern == rt.Pattern() {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotent reports whether a POST route honours Idempotency-Key via
+// IDEMPOTENCY_ROUTES.
+func idempotent(rt Route, cfg *config.Config) bool {
+	if rt.Method != http.MethodPost {
+		return false
+	}
+	for _, pattern := range cfg.IdempotencyRoutes {
+		if pattern == rt.Pattern() {
+			return true
+		}
+	}
+	return false
+}
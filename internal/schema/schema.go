@@ -0,0 +1,333 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// USER-041: JSON Schema request validation
+// Schema is the subset of JSON Schema the gateway validates: type,
+// properties, required, additionalProperties (boolean), items, enum,
+// minimum/maximum (inclusive and exclusive), minLength/maxLength, pattern,
+// minItems/maxItems and format (email, date-time, uuid). Other keywords are
+// accepted and ignored.
+type Schema struct {
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Format               string             `json:"format,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Types holds the "type" keyword, which may be a string or a list.
+type Types []string
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Violation is one way a document fails its schema. Path is a JSON Pointer
+// to the offending value ("" for the document root).
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Parse decodes and compiles a schema document.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile(path string) error {
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("%s: unknown type %q", pointer(path), t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", pointer(path), err)
+		}
+		s.pattern = re
+	}
+	for name, prop := range s.Properties {
+		if err := prop.compile(path + "/properties/" + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.compile(path + "/items"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns every violation of s in doc, a value produced by
+// encoding/json. An empty result means doc is valid.
+func (s *Schema) Validate(doc interface{}) []Violation {
+	var violations []Violation
+	s.validate(doc, "", &violations)
+	return violations
+}
+
+func (s *Schema) validate(v interface{}, path string, out *[]Violation) {
+	add := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		add("must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		add("must be one of %s", enumList(s.Enum))
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(val, path, out)
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			add("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			add("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(item, path+"/"+strconv.Itoa(i), out)
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if s.MinLength != nil && n < *s.MinLength {
+			add("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			add("must match pattern %s", s.Pattern)
+		}
+		if s.Format != "" && !validFormat(s.Format, val) {
+			add("must be a valid %s", s.Format)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			add("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			add("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && val <= *s.ExclusiveMinimum {
+			add("must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && val >= *s.ExclusiveMaximum {
+			add("must be < %v", *s.ExclusiveMaximum)
+		}
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, path string, out *[]Violation) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*out = append(*out, Violation{Path: path + "/" + escape(name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*out = append(*out, Violation{Path: path + "/" + escape(name), Message: "is not allowed"})
+			}
+			continue
+		}
+		prop.validate(obj[name], path+"/"+escape(name), out)
+	}
+}
+
+func (t Types) matches(v interface{}) bool {
+	for _, typ := range t {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case float64:
+			if typ == "number" || (typ == "integer" && val == math.Trunc(val)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(v, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []interface{}) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		b, _ := json.Marshal(e)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func validFormat(format, v string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(v)
+		return err == nil && addr.Address == v
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(v)
+	}
+	return true
+}
+
+// escape encodes a property name as a JSON Pointer reference token.
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "#"
+	}
+	return "#" + path
+}
+
+// Registry holds named schemas loaded from a directory.
+type Registry struct {
+	schemas map[string]*Schema
+}
+
+// LoadDir loads every <name>.json in dir as the schema called name. A
+// missing directory yields an empty registry.
+func LoadDir(dir string) (*Registry, error) {
+	reg := &Registry{schemas: make(map[string]*Schema)}
+	if dir == "" {
+		return reg, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		s, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", filepath.Base(file), err)
+		}
+		reg.schemas[strings.TrimSuffix(filepath.Base(file), ".json")] = s
+	}
+
+	return reg, nil
+}
+
+// Add registers a schema under name.
+func (r *Registry) Add(name string, s *Schema) {
+	r.schemas[name] = s
+}
+
+// Get returns the schema called name.
+func (r *Registry) Get(name string) (*Schema, bool) {
+	s, ok := r.schemas[name]
+	return s, ok
+}
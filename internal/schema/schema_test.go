@@ -0,0 +1,84 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/schema"
+)
+
+const paymentSchema = `{
+  "type": "object",
+  "required": ["order_id", "amount"],
+  "additionalProperties": false,
+  "properties": {
+    "order_id": {"type": "string", "minLength": 1},
+    "amount": {"type": "number", "exclusiveMinimum": 0},
+    "currency": {"type": "string", "enum": ["USD", "EUR"]},
+    "email": {"type": "string", "format": "email"},
+    "items": {"type": "array", "maxItems": 2, "items": {"type": "integer", "minimum": 1}}
+  }
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := schema.Parse([]byte(paymentSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		doc   string
+		paths []string
+	}{
+		{"valid", `{"order_id":"ord_1","amount":10.5,"currency":"USD"}`, nil},
+		{"not an object", `[1]`, []string{""}},
+		{"missing and negative", `{"amount":-1}`, []string{"/order_id", "/amount"}},
+		{"wrong types", `{"order_id":5,"amount":"10"}`, []string{"/amount", "/order_id"}},
+		{"enum and format", `{"order_id":"o","amount":1,"currency":"GBP","email":"nope"}`, []string{"/currency", "/email"}},
+		{"array items", `{"order_id":"o","amount":1,"items":[1,0,2.5]}`, []string{"/items", "/items/1", "/items/2"}},
+		{"unknown property", `{"order_id":"o","amount":1,"extra":true}`, []string{"/extra"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("bad test document: %v", err)
+			}
+
+			violations := s.Validate(doc)
+			if len(violations) != len(tt.paths) {
+				t.Fatalf("expected %d violations, got %v", len(tt.paths), violations)
+			}
+			for i, v := range violations {
+				if v.Path != tt.paths[i] {
+					t.Errorf("violation %d: expected path %q, got %q (%s)", i, tt.paths[i], v.Path, v.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_InvalidSchema(t *testing.T) {
+	for _, doc := range []string{
+		`{"type": "decimal"}`,
+		`{"properties": {"id": {"type": "string", "pattern": "("}}}`,
+	} {
+		if _, err := schema.Parse([]byte(doc)); err == nil {
+			t.Errorf("expected error for %s", doc)
+		}
+	}
+}
+
+func TestLoadDir_ShippedSchemas(t *testing.T) {
+	reg, err := schema.LoadDir("../../configs/schemas")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	for _, name := range []string{"order_create", "payment_create", "payment_refund", "user_create"} {
+		if _, ok := reg.Get(name); !ok {
+			t.Errorf("expected schema %q", name)
+		}
+	}
+}
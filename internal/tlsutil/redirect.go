@@ -0,0 +1,27 @@
+package tlsutil
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectHandler sends plain HTTP requests to the same host and path over
+// HTTPS on httpsPort. Requests other than GET and HEAD get a 308 so clients
+// repeat the same method and body rather than switching to GET.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
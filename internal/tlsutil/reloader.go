@@ -0,0 +1,156 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// CertPair is a PEM certificate chain and its private key on disk.
+type CertPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// USER-034: Certificate hot reload with SNI selection
+// CertReloader serves certificates loaded from files, picks one per
+// connection by SNI, and reloads the files when they change on disk.
+type CertReloader struct {
+	pairs []CertPair
+
+	mu      sync.RWMutex
+	certs   []*tls.Certificate
+	modTime []time.Time
+}
+
+// NewCertReloader loads every pair. The first pair is the default served
+// to clients that send no SNI or an unknown name.
+func NewCertReloader(pairs []CertPair) (*CertReloader, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+
+	r := &CertReloader{
+		pairs:   pairs,
+		certs:   make([]*tls.Certificate, len(pairs)),
+		modTime: make([]time.Time, len(pairs)),
+	}
+
+	for i := range pairs {
+		if err := r.load(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *CertReloader) load(i int) error {
+	pair := r.pairs[i]
+
+	cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", pair.CertFile, err)
+	}
+	if cert.Leaf == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate %s: %w", pair.CertFile, err)
+		}
+		cert.Leaf = leaf
+	}
+
+	modTime, err := latestModTime(pair)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.certs[i] = &cert
+	r.modTime[i] = modTime
+	r.mu.Unlock()
+
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		for _, cert := range r.certs {
+			if cert.Leaf != nil && cert.Leaf.VerifyHostname(name) == nil {
+				return cert, nil
+			}
+		}
+	}
+
+	return r.certs[0], nil
+}
+
+// Watch polls the certificate files every interval and reloads any pair
+// whose files changed. A pair that fails to load keeps serving its previous
+// certificate. Watch returns when stop is closed.
+func (r *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reloadChanged()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (r *CertReloader) reloadChanged() {
+	for i, pair := range r.pairs {
+		modTime, err := latestModTime(pair)
+		if err != nil {
+			logging.Warn("Certificate stat failed", logging.Fields{"cert_file": pair.CertFile, "error": err.Error()})
+			continue
+		}
+
+		r.mu.RLock()
+		changed := modTime.After(r.modTime[i])
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.load(i); err != nil {
+			logging.Error("Certificate reload failed, keeping previous certificate", logging.Fields{
+				"cert_file": pair.CertFile,
+				"error":     err.Error(),
+			})
+			continue
+		}
+
+		logging.Info("Certificate reloaded", logging.Fields{"cert_file": pair.CertFile})
+	}
+}
+
+func latestModTime(pair CertPair) (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{pair.CertFile, pair.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
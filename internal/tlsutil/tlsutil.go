@@ -0,0 +1,120 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+)
+
+// CertPairs returns the configured certificate/key pairs: TLS_CERT_FILE and
+// TLS_KEY_FILE first, followed by each "cert:key" entry in TLS_CERTIFICATES.
+// No pairs means HTTPS is not configured. A half-configured default pair or
+// a malformed entry is an error.
+func CertPairs(cfg *config.Config) ([]CertPair, error) {
+	var pairs []CertPair
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSCertFile != "" {
+		pairs = append(pairs, CertPair{CertFile: cfg.TLSCertFile, KeyFile: cfg.TLSKeyFile})
+	}
+	for _, entry := range cfg.TLSCertificates {
+		certFile, keyFile, ok := strings.Cut(entry, ":")
+		if !ok || certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("TLS_CERTIFICATES entry %q must look like cert:key", entry)
+		}
+		pairs = append(pairs, CertPair{CertFile: certFile, KeyFile: keyFile})
+	}
+	return pairs, nil
+}
+
+// ServerConfig builds the TLS configuration for the public listener.
+// Certificates are served by reloader, selected by SNI. When a client CA
+// bundle is configured, client certificates are requested and verified
+// against it; requests without one still reach JWT-authenticated routes.
+func ServerConfig(cfg *config.Config, reloader *CertReloader) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := ParseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pool, err := LoadCAPool(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsCfg, nil
+}
+
+// LoadCAPool reads a PEM bundle of CA certificates.
+func LoadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found in CA bundle")
+	}
+	return pool, nil
+}
+
+// ParseVersion converts "1.0" through "1.3" to a tls.Version constant.
+func ParseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q", v)
+}
+
+// ParseCipherSuites converts IANA cipher suite names to IDs. Only suites
+// Go considers secure are accepted. An empty list keeps Go's defaults.
+// TLS 1.3 suites are not configurable and are ignored by crypto/tls.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,145 @@
+package tlsutil_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/config"
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/tlsutil"
+)
+
+func writeCert(t *testing.T, dir, name string, serial int64) tlsutil.CertPair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	pair := tlsutil.CertPair{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	os.WriteFile(pair.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(pair.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return pair
+}
+
+func TestCertReloader_SNIAndReload(t *testing.T) {
+	dir := t.TempDir()
+	shop := writeCert(t, dir, "shop.example.com", 1)
+	api := writeCert(t, dir, "api.example.com", 2)
+
+	reloader, err := tlsutil.NewCertReloader([]tlsutil.CertPair{shop, api})
+	if err != nil {
+		t.Fatalf("failed to create reloader: %v", err)
+	}
+
+	cert, _ := reloader.GetCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+	if cert.Leaf.Subject.CommonName != "api.example.com" {
+		t.Errorf("expected api certificate, got '%s'", cert.Leaf.Subject.CommonName)
+	}
+
+	cert, _ = reloader.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.example.com"})
+	if cert.Leaf.Subject.CommonName != "shop.example.com" {
+		t.Errorf("expected default certificate, got '%s'", cert.Leaf.Subject.CommonName)
+	}
+
+	writeCert(t, dir, "api.example.com", 3)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(api.CertFile, future, future)
+
+	stop := make(chan struct{})
+	go reloader.Watch(10*time.Millisecond, stop)
+	defer close(stop)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		cert, _ = reloader.GetCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+		if cert.Leaf.SerialNumber.Int64() == 3 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected reloaded certificate with serial 3")
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{http.MethodGet, http.StatusMovedPermanently},
+		{http.MethodHead, http.StatusMovedPermanently},
+		{http.MethodPost, http.StatusPermanentRedirect},
+		{http.MethodPut, http.StatusPermanentRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://shop.example.com/api/v2/orders?page=2", nil)
+			w := httptest.NewRecorder()
+
+			tlsutil.RedirectHandler("8443").ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+			if loc := w.Header().Get("Location"); loc != "https://shop.example.com:8443/api/v2/orders?page=2" {
+				t.Errorf("unexpected redirect location '%s'", loc)
+			}
+		})
+	}
+}
+
+func TestCertPairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    int
+		wantErr bool
+	}{
+		{"none", config.Config{}, 0, false},
+		{"default and extra", config.Config{TLSCertFile: "a.crt", TLSKeyFile: "a.key", TLSCertificates: []string{"b.crt:b.key"}}, 2, false},
+		{"cert without key", config.Config{TLSCertFile: "a.crt"}, 0, true},
+		{"malformed entry", config.Config{TLSCertificates: []string{"b.crt"}}, 0, true},
+		{"empty key", config.Config{TLSCertificates: []string{"b.crt:"}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs, err := tlsutil.CertPairs(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(pairs) != tt.want {
+				t.Errorf("expected %d pairs, got %d", tt.want, len(pairs))
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package translate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var services = map[string]bool{"users": true, "orders": true, "payments": true, "notifications": true}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Transforms that may be applied to a mapped field.
+var transforms = map[string]func(v interface{}) (interface{}, error){
+	"string": func(v interface{}) (interface{}, error) {
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		}
+		return nil, errors.New("must be a scalar")
+	},
+	"number": func(v interface{}) (interface{}, error) {
+		switch val := v.(type) {
+		case float64:
+			return val, nil
+		case string:
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, errors.New("must be numeric")
+			}
+			return f, nil
+		}
+		return nil, errors.New("must be numeric")
+	},
+	"cents_to_decimal": func(v interface{}) (interface{}, error) {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		return f / 100, nil
+	},
+	"decimal_to_cents": func(v interface{}) (interface{}, error) {
+		f, ok := v.(float64)
+		if !ok {
+			return nil, errors.New("must be a number")
+		}
+		return math.Round(f * 100), nil
+	},
+	"lower": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		return strings.ToLower(s), nil
+	},
+	"upper": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("must be a string")
+		}
+		return strings.ToUpper(s), nil
+	},
+}
+
+// Caller is the authenticated identity a mapping can stamp into a payload.
+type Caller struct {
+	UserID string
+	Role   string
+}
+
+// callerFields are the names FromCaller accepts.
+var callerFields = map[string]func(Caller) string{
+	"user_id": func(c Caller) string { return c.UserID },
+	"role":    func(c Caller) string { return c.Role },
+}
+
+// Field copies one value between payloads. From and To are dot-separated
+// paths into the source and destination objects. When From is absent or
+// missing from the source, Default is used if set. FromCaller instead sets
+// To from the caller's identity, overriding anything the client sent.
+// Transform names a conversion applied to the copied value.
+type Field struct {
+	From       string      `json:"from,omitempty"`
+	FromCaller string      `json:"from_caller,omitempty"`
+	To         string      `json:"to"`
+	Default    interface{} `json:"default,omitempty"`
+	Transform  string      `json:"transform,omitempty"`
+}
+
+// Mapping rewrites a JSON object field by field. KeepUnmapped copies the
+// source's top-level fields that no rule reads from.
+type Mapping struct {
+	Fields       []Field `json:"fields"`
+	KeepUnmapped bool    `json:"keep_unmapped,omitempty"`
+}
+
+// USER-048: v1 routes served by v2 upstreams
+// Rule serves a v1 route by calling a v2 endpoint. Path may contain the
+// v1 route's {param} wildcards. Request maps the v1 body to the v2 body and
+// Response maps successful v2 responses back to the v1 shape; a nil mapping
+// passes the payload through unchanged. Schema names the SCHEMA_DIR schema
+// the mapped request body must satisfy, as on the v2 route.
+type Rule struct {
+	Route    string   `json:"route"`
+	Service  string   `json:"service"`
+	Method   string   `json:"method"`
+	Path     string   `json:"path"`
+	Schema   string   `json:"schema,omitempty"`
+	Request  *Mapping `json:"request,omitempty"`
+	Response *Mapping `json:"response,omitempty"`
+}
+
+// Load reads translation rules keyed by v1 route pattern. A missing file
+// yields no rules, leaving every v1 route on its legacy handler.
+func Load(path string) (map[string]*Rule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read v1 translations: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes and checks translation rules.
+func Parse(data []byte) (map[string]*Rule, error) {
+	var list []*Rule
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse v1 translations: %w", err)
+	}
+
+	rules := make(map[string]*Rule, len(list))
+	for _, rule := range list {
+		if err := rule.check(); err != nil {
+			return nil, fmt.Errorf("v1 translation %s: %w", rule.Route, err)
+		}
+		if rules[rule.Route] != nil {
+			return nil, fmt.Errorf("v1 translation %s: duplicate route", rule.Route)
+		}
+		rules[rule.Route] = rule
+	}
+	return rules, nil
+}
+
+func (r *Rule) check() error {
+	_, routePath, ok := strings.Cut(r.Route, " ")
+	if !ok || !strings.HasPrefix(routePath, "/") {
+		return errors.New(`route must look like "METHOD /path"`)
+	}
+	if !services[r.Service] {
+		return fmt.Errorf("unknown service %q", r.Service)
+	}
+	if r.Method == "" {
+		return errors.New("method is required")
+	}
+	r.Method = strings.ToUpper(r.Method)
+	if !strings.HasPrefix(r.Path, "/") {
+		return errors.New("path must start with /")
+	}
+
+	params := make(map[string]bool)
+	for _, m := range pathParam.FindAllStringSubmatch(routePath, -1) {
+		params[m[1]] = true
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		if !params[m[1]] {
+			return fmt.Errorf("path parameter {%s} is not in the v1 route", m[1])
+		}
+	}
+
+	for _, m := range []*Mapping{r.Request, r.Response} {
+		if m == nil {
+			continue
+		}
+		for _, f := range m.Fields {
+			if f.To == "" {
+				return errors.New("every field needs a destination")
+			}
+			if f.FromCaller != "" {
+				if _, ok := callerFields[f.FromCaller]; !ok {
+					return fmt.Errorf("field %q: unknown caller field %q", f.To, f.FromCaller)
+				}
+				if f.From != "" || f.Default != nil {
+					return fmt.Errorf("field %q: from_caller excludes from and default", f.To)
+				}
+			} else if f.From == "" && f.Default == nil {
+				return fmt.Errorf("field %q needs a source or a default", f.To)
+			}
+			if _, ok := transforms[f.Transform]; f.Transform != "" && !ok {
+				return fmt.Errorf("field %q: unknown transform %q", f.To, f.Transform)
+			}
+		}
+	}
+	return nil
+}
+
+// UpstreamPath fills the rule's path from the v1 request's wildcards and
+// keeps its query string.
+func (r *Rule) UpstreamPath(req *http.Request) string {
+	path := pathParam.ReplaceAllStringFunc(r.Path, func(p string) string {
+		return url.PathEscape(req.PathValue(pathParam.FindStringSubmatch(p)[1]))
+	})
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	return path
+}
+
+// Apply maps v, a decoded JSON value, on behalf of caller. Arrays are mapped
+// element by element and non-object values are returned unchanged.
+func (m *Mapping) Apply(v interface{}, caller Caller) (interface{}, error) {
+	if m == nil {
+		return v, nil
+	}
+
+	switch val := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			mapped, err := m.Apply(item, caller)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	case map[string]interface{}:
+		return m.object(val, caller)
+	}
+	return v, nil
+}
+
+func (m *Mapping) object(src map[string]interface{}, caller Caller) (map[string]interface{}, error) {
+	dst := make(map[string]interface{})
+
+	if m.KeepUnmapped {
+		read := make(map[string]bool, len(m.Fields))
+		for _, f := range m.Fields {
+			root, _, _ := strings.Cut(f.From, ".")
+			read[root] = true
+		}
+		for k, v := range src {
+			if !read[k] {
+				dst[k] = v
+			}
+		}
+	}
+
+	for _, f := range m.Fields {
+		if f.FromCaller != "" {
+			set(dst, f.To, callerFields[f.FromCaller](caller))
+			continue
+		}
+
+		v, ok := lookup(src, f.From)
+		if !ok {
+			if f.Default == nil {
+				continue
+			}
+			v = f.Default
+		}
+		if f.Transform != "" && v != nil {
+			converted, err := transforms[f.Transform](v)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s", displayName(f), err)
+			}
+			v = converted
+		}
+		set(dst, f.To, v)
+	}
+	return dst, nil
+}
+
+func displayName(f Field) string {
+	if f.From != "" {
+		return f.From
+	}
+	return f.To
+}
+
+func lookup(obj map[string]interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	var cur interface{} = obj
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func set(obj map[string]interface{}, path string, v interface{}) {
+	segs := strings.Split(path, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		child, ok := obj[seg].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			obj[seg] = child
+		}
+		obj = child
+	}
+	obj[segs[len(segs)-1]] = v
+}
@@ -0,0 +1,124 @@
+package translate_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/translate"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want string
+	}{
+		{"bad route", `[{"route":"/api/v1/users","service":"users","method":"GET","path":"/api/v2/users"}]`, "METHOD /path"},
+		{"unknown service", `[{"route":"GET /api/v1/carts","service":"carts","method":"GET","path":"/api/v2/carts"}]`, "unknown service"},
+		{"unknown parameter", `[{"route":"GET /api/v1/users/{id}","service":"users","method":"GET","path":"/api/v2/users/{key}"}]`, "{key} is not in the v1 route"},
+		{"unknown transform", `[{"route":"POST /api/v1/users","service":"users","method":"POST","path":"/api/v2/users","request":{"fields":[{"from":"a","to":"b","transform":"rot13"}]}}]`, "unknown transform"},
+		{"no source", `[{"route":"POST /api/v1/users","service":"users","method":"POST","path":"/api/v2/users","request":{"fields":[{"to":"b"}]}}]`, "needs a source or a default"},
+		{"unknown caller field", `[{"route":"POST /api/v1/payments","service":"payments","method":"POST","path":"/api/v2/payments","request":{"fields":[{"from_caller":"email","to":"email"}]}}]`, "unknown caller field"},
+		{"duplicate", `[{"route":"GET /api/v1/users","service":"users","method":"GET","path":"/api/v2/users"},{"route":"GET /api/v1/users","service":"users","method":"GET","path":"/api/v2/users"}]`, "duplicate route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := translate.Parse([]byte(tt.def))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMapping_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "rename and nest",
+			mapping: `{"fields":[{"from":"full_name","to":"name"},{"from":"address.zip","to":"shipping.postal_code"}]}`,
+			in:      `{"full_name":"Ada","address":{"zip":"12345"},"extra":true}`,
+			want:    `{"name":"Ada","shipping":{"postal_code":"12345"}}`,
+		},
+		{
+			name:    "keep unmapped",
+			mapping: `{"keep_unmapped":true,"fields":[{"from":"name","to":"full_name"}]}`,
+			in:      `{"id":"u1","name":"Ada"}`,
+			want:    `{"id":"u1","full_name":"Ada"}`,
+		},
+		{
+			name:    "defaults and transforms",
+			mapping: `{"fields":[{"from":"amount_cents","to":"amount","transform":"cents_to_decimal"},{"from":"currency","to":"currency","default":"usd","transform":"upper"},{"to":"source","default":"v1"}]}`,
+			in:      `{"amount_cents":1999}`,
+			want:    `{"amount":19.99,"currency":"USD","source":"v1"}`,
+		},
+		{
+			name:    "arrays",
+			mapping: `{"fields":[{"from":"total","to":"total_cents","transform":"decimal_to_cents"}]}`,
+			in:      `[{"total":1.5},{"total":2}]`,
+			want:    `[{"total_cents":150},{"total_cents":200}]`,
+		},
+		{
+			name:    "caller fields override the client",
+			mapping: `{"keep_unmapped":true,"fields":[{"from_caller":"user_id","to":"user_id"}]}`,
+			in:      `{"order_id":"ord_1","user_id":"someone_else"}`,
+			want:    `{"order_id":"ord_1","user_id":"user_1"}`,
+		},
+		{
+			name:    "bad value",
+			mapping: `{"fields":[{"from":"amount_cents","to":"amount","transform":"cents_to_decimal"}]}`,
+			in:      `{"amount_cents":"lots"}`,
+			wantErr: "amount_cents must be a number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m translate.Mapping
+			var in, want interface{}
+			json.Unmarshal([]byte(tt.mapping), &m)
+			json.Unmarshal([]byte(tt.in), &in)
+
+			got, err := m.Apply(in, translate.Caller{UserID: "user_1"})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestRule_UpstreamPath(t *testing.T) {
+	rules, err := translate.Parse([]byte(`[{"route":"GET /api/v1/users/{id}","service":"users","method":"get","path":"/api/v2/users/{id}"}]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rule := rules["GET /api/v1/users/{id}"]
+	if rule.Method != "GET" {
+		t.Errorf("expected method to be normalised, got %q", rule.Method)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/users/a%2Fb?fields=email", nil)
+	req.SetPathValue("id", "a/b")
+	if got := rule.UpstreamPath(req); got != "/api/v2/users/a%2Fb?fields=email" {
+		t.Errorf("unexpected upstream path %q", got)
+	}
+}
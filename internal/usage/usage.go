@@ -0,0 +1,252 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+const (
+	// maxRecords bounds memory and file size. Callers first seen after the
+	// limit is reached are counted under an overflow record for the route.
+	maxRecords = 10000
+
+	maxUserAgent = 256
+	overflow     = "(other)"
+)
+
+// How a caller authenticated, recorded in Caller.Auth. Requests rejected
+// before authentication completes have no method.
+const (
+	AuthJWT          = "jwt"
+	AuthAPIKey       = "api_key"
+	AuthLegacyHeader = "legacy_header"
+)
+
+// Caller identifies who made a request. Any field may be empty.
+type Caller struct {
+	Auth      string
+	UserID    string
+	APIKeyID  string
+	ClientIP  string
+	UserAgent string
+}
+
+// Record counts requests from one caller to one route.
+type Record struct {
+	Route     string    `json:"route"`
+	Auth      string    `json:"auth,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	APIKeyID  string    `json:"api_key_id,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// USER-050: Per-caller usage of deprecated routes
+// Tracker counts requests in memory and periodically writes them to path,
+// so counts survive restarts.
+type Tracker struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]*Record
+	dirty   bool
+
+	// flushMu serializes writes to path.
+	flushMu sync.Mutex
+}
+
+// Open loads records from path. A missing file yields an empty tracker;
+// an empty path keeps records in memory only.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{path: path, records: make(map[string]*Record)}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage records: %w", err)
+	}
+
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse usage records: %w", err)
+	}
+	for _, rec := range records {
+		t.records[key(rec.Route, rec.caller())] = rec
+	}
+	return t, nil
+}
+
+// Record counts one request from c to route.
+func (t *Tracker) Record(route string, c Caller) {
+	if len(c.UserAgent) > maxUserAgent {
+		c.UserAgent = c.UserAgent[:maxUserAgent]
+	}
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key(route, c)
+	rec, ok := t.records[k]
+	if !ok && len(t.records) >= maxRecords {
+		c = Caller{Auth: overflow, UserID: overflow, APIKeyID: overflow, ClientIP: overflow, UserAgent: overflow}
+		k = key(route, c)
+		rec, ok = t.records[k]
+	}
+	if !ok {
+		rec = &Record{
+			Route:     route,
+			Auth:      c.Auth,
+			UserID:    c.UserID,
+			APIKeyID:  c.APIKeyID,
+			ClientIP:  c.ClientIP,
+			UserAgent: c.UserAgent,
+			FirstSeen: now,
+		}
+		t.records[k] = rec
+	}
+	rec.Count++
+	rec.LastSeen = now
+	t.dirty = true
+}
+
+// Snapshot returns all records, most recently seen first.
+func (t *Tracker) Snapshot() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records := make([]Record, 0, len(t.records))
+	for _, rec := range t.records {
+		records = append(records, *rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastSeen.After(records[j].LastSeen)
+	})
+	return records
+}
+
+// Flush writes the records to disk if they changed since the last flush.
+func (t *Tracker) Flush() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	records := make([]*Record, 0, len(t.records))
+	for _, rec := range t.records {
+		copied := *rec
+		records = append(records, &copied)
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return key(records[i].Route, records[i].caller()) < key(records[j].Route, records[j].caller())
+	})
+	if err := t.write(records); err != nil {
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (t *Tracker) write(records []*Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage records: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o700); err != nil {
+		return fmt.Errorf("failed to write usage records: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write usage records: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("failed to write usage records: %w", err)
+	}
+	return nil
+}
+
+// Run flushes every interval until stop is closed.
+func (t *Tracker) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				logging.Error("Failed to persist v1 usage", logging.Fields{"error": err.Error()})
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// WriteCSV writes records with a header row. Caller-supplied values that a
+// spreadsheet would treat as formulas are prefixed with a quote.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"route", "auth", "user_id", "api_key_id", "client_ip", "user_agent", "count", "first_seen", "last_seen"})
+	for _, rec := range records {
+		cw.Write([]string{
+			rec.Route,
+			rec.Auth,
+			csvCell(rec.UserID),
+			csvCell(rec.APIKeyID),
+			csvCell(rec.ClientIP),
+			csvCell(rec.UserAgent),
+			strconv.FormatInt(rec.Count, 10),
+			rec.FirstSeen.Format(time.RFC3339),
+			rec.LastSeen.Format(time.RFC3339),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (r *Record) caller() Caller {
+	return Caller{Auth: r.Auth, UserID: r.UserID, APIKeyID: r.APIKeyID, ClientIP: r.ClientIP, UserAgent: r.UserAgent}
+}
+
+func key(route string, c Caller) string {
+	return strings.Join([]string{route, c.Auth, c.UserID, c.APIKeyID, c.ClientIP, c.UserAgent}, "\x00")
+}
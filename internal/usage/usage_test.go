@@ -0,0 +1,64 @@
+package usage_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/usage"
+)
+
+func TestTracker_PersistsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage", "v1_usage.json")
+
+	tracker, err := usage.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	alice := usage.Caller{UserID: "user_1", ClientIP: "10.0.0.1", UserAgent: "legacy-app/1.0"}
+	tracker.Record("GET /api/v1/users/{id}", alice)
+	tracker.Record("GET /api/v1/users/{id}", alice)
+	tracker.Record("GET /api/v1/users/{id}", usage.Caller{UserID: "svc", APIKeyID: "key_1", ClientIP: "10.0.0.2"})
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := usage.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reopened.Record("GET /api/v1/users/{id}", alice)
+
+	records := reopened.Snapshot()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].UserID != "user_1" || records[0].Count != 3 {
+		t.Errorf("expected user_1 seen 3 times and most recently, got %+v", records[0])
+	}
+	if records[1].APIKeyID != "key_1" || records[1].Count != 1 {
+		t.Errorf("unexpected API key record: %+v", records[1])
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tracker, _ := usage.Open("")
+	tracker.Record("POST /api/v1/payments", usage.Caller{Auth: usage.AuthLegacyHeader, UserID: "user_1", UserAgent: "=HYPERLINK(\"x\")"})
+
+	var buf bytes.Buffer
+	if err := usage.WriteCSV(&buf, tracker.Snapshot()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row, got %q", buf.String())
+	}
+	if lines[0] != "route,auth,user_id,api_key_id,client_ip,user_agent,count,first_seen,last_seen" {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `POST /api/v1/payments,legacy_header,user_1,,,"'=HYPERLINK(""x"")",1,`) {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+}
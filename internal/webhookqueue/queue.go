@@ -0,0 +1,452 @@
+package webhookqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+var ErrNotFound = errors.New("webhook message not found")
+
+// Message is a verified webhook awaiting delivery, or dead-lettered after
+// delivery gave up.
+type Message struct {
+	ID          string          `json:"id"`
+	EventID     string          `json:"event_id"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+	DeadAt      *time.Time      `json:"dead_at,omitempty"`
+}
+
+// DeliverFunc sends a message to the payments service. Errors wrapped with
+// Permanent dead-letter the message without further retries.
+type DeliverFunc func(ctx context.Context, msg *Message) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a delivery error as not worth retrying.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Options tunes retries. Zero values take the defaults below.
+type Options struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+const (
+	defaultMaxAttempts = 10
+	defaultBaseBackoff = 2 * time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+	idlePoll           = time.Minute
+)
+
+// USER-040: Durable webhook ingestion
+// Queue persists each message as its own file under dir/pending, synced
+// before Enqueue returns, and moves it to dir/dead once retries run out.
+// Delivered messages are deleted. Files are reloaded on startup, so a
+// restart resumes where delivery left off.
+type Queue struct {
+	dir     string
+	deliver DeliverFunc
+	opts    Options
+
+	mu      sync.Mutex
+	pending map[string]*Message
+	dead    map[string]*Message
+	wake    chan struct{}
+}
+
+// Open loads the queue stored in dir, creating it if needed.
+func Open(dir string, deliver DeliverFunc, opts Options) (*Queue, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = defaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+
+	q := &Queue{
+		dir:     dir,
+		deliver: deliver,
+		opts:    opts,
+		pending: make(map[string]*Message),
+		dead:    make(map[string]*Message),
+		wake:    make(chan struct{}, 1),
+	}
+
+	for _, sub := range []string{"pending", "dead"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create webhook queue: %w", err)
+		}
+	}
+	if err := q.load("pending", q.pending); err != nil {
+		return nil, err
+	}
+	if err := q.load("dead", q.dead); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (q *Queue) load(sub string, into map[string]*Message) error {
+	files, err := filepath.Glob(filepath.Join(q.dir, sub, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list webhook queue: %w", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read webhook message: %w", err)
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("failed to parse webhook message %s: %w", file, err)
+		}
+		into[msg.ID] = &msg
+	}
+	return nil
+}
+
+// Enqueue durably stores a webhook for delivery. Once it returns nil the
+// provider can be acknowledged.
+func (q *Queue) Enqueue(eventID string, payload []byte) (*Message, error) {
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	msg := &Message{
+		ID:          id,
+		EventID:     eventID,
+		Payload:     json.RawMessage(payload),
+		ReceivedAt:  now,
+		NextAttempt: now,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.write("pending", msg); err != nil {
+		return nil, err
+	}
+	q.pending[msg.ID] = msg
+	q.notify()
+
+	return msg, nil
+}
+
+// Dead returns dead-lettered messages, oldest first.
+func (q *Queue) Dead() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	msgs := make([]Message, 0, len(q.dead))
+	for _, msg := range q.dead {
+		msgs = append(msgs, *msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].ReceivedAt.Before(msgs[j].ReceivedAt)
+	})
+	return msgs
+}
+
+// Pending returns the number of messages awaiting delivery.
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Replay moves a dead-lettered message back to pending with its attempt
+// count reset.
+func (q *Queue) Replay(id string) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	msg, ok := q.dead[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	replayed := *msg
+	replayed.Attempts = 0
+	replayed.NextAttempt = time.Now().UTC()
+	replayed.DeadAt = nil
+
+	if err := q.write("pending", &replayed); err != nil {
+		return nil, err
+	}
+	if err := q.remove("dead", id); err != nil {
+		return nil, fmt.Errorf("failed to remove dead webhook message: %w", err)
+	}
+	delete(q.dead, id)
+	q.pending[id] = &replayed
+	q.notify()
+
+	return &replayed, nil
+}
+
+// Run delivers due messages until stop is closed.
+func (q *Queue) Run(stop <-chan struct{}) {
+	for {
+		q.deliverDue(stop)
+
+		timer := time.NewTimer(q.untilNext())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverDue delivers the messages that are due. Closing stop cancels a
+// delivery in flight; the message stays pending as it was.
+func (q *Queue) deliverDue(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for _, msg := range q.due(time.Now()) {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// A message whose dead-lettering failed is moved, not redelivered.
+		if msg.DeadAt != nil {
+			q.mu.Lock()
+			q.deadLetter(msg)
+			q.mu.Unlock()
+			continue
+		}
+
+		err := q.deliver(ctx, &msg)
+		if ctx.Err() != nil {
+			return
+		}
+
+		q.mu.Lock()
+		q.settle(msg, err)
+		q.mu.Unlock()
+	}
+}
+
+// due returns copies of the pending messages ready for delivery, oldest first.
+func (q *Queue) due(now time.Time) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var msgs []Message
+	for _, msg := range q.pending {
+		if !msg.NextAttempt.After(now) {
+			msgs = append(msgs, *msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].ReceivedAt.Before(msgs[j].ReceivedAt)
+	})
+	return msgs
+}
+
+// settle records the outcome of a delivery attempt. Callers hold q.mu.
+func (q *Queue) settle(msg Message, err error) {
+	fields := logging.Fields{
+		"webhook_id": msg.ID,
+		"event_id":   msg.EventID,
+		"attempt":    msg.Attempts + 1,
+	}
+
+	if err == nil {
+		if rmErr := q.remove("pending", msg.ID); rmErr != nil {
+			logging.Error("Failed to remove delivered webhook", logging.Fields{"webhook_id": msg.ID, "error": rmErr.Error()})
+		}
+		delete(q.pending, msg.ID)
+		logging.Info("Webhook delivered", fields)
+		return
+	}
+
+	msg.Attempts++
+	msg.LastError = err.Error()
+	fields["error"] = err.Error()
+
+	var permanent permanentError
+	if errors.As(err, &permanent) || msg.Attempts >= q.opts.MaxAttempts {
+		now := time.Now().UTC()
+		msg.DeadAt = &now
+		q.deadLetter(msg)
+		return
+	}
+
+	msg.NextAttempt = time.Now().UTC().Add(q.backoff(msg.Attempts))
+	if wErr := q.write("pending", &msg); wErr != nil {
+		logging.Error("Failed to persist webhook retry", logging.Fields{"webhook_id": msg.ID, "error": wErr.Error()})
+	}
+	q.pending[msg.ID] = &msg
+	fields["next_attempt"] = msg.NextAttempt
+	logging.Warn("Webhook delivery failed, will retry", fields)
+}
+
+// deadLetter moves msg from pending to dead. If the dead letter cannot be
+// written, msg stays pending with its DeadAt and attempts persisted, and a
+// later pass retries the move. Callers hold q.mu.
+func (q *Queue) deadLetter(msg Message) {
+	fields := logging.Fields{
+		"webhook_id": msg.ID,
+		"event_id":   msg.EventID,
+		"attempts":   msg.Attempts,
+		"error":      msg.LastError,
+	}
+
+	if wErr := q.write("dead", &msg); wErr != nil {
+		logging.Error("Failed to dead-letter webhook", logging.Fields{"webhook_id": msg.ID, "error": wErr.Error()})
+		msg.NextAttempt = time.Now().UTC().Add(q.opts.BaseBackoff)
+		if wErr := q.write("pending", &msg); wErr != nil {
+			logging.Error("Failed to persist webhook retry", logging.Fields{"webhook_id": msg.ID, "error": wErr.Error()})
+		}
+		q.pending[msg.ID] = &msg
+		return
+	}
+	if rmErr := q.remove("pending", msg.ID); rmErr != nil {
+		logging.Error("Failed to remove dead-lettered webhook from pending", logging.Fields{"webhook_id": msg.ID, "error": rmErr.Error()})
+	}
+	delete(q.pending, msg.ID)
+	q.dead[msg.ID] = &msg
+	logging.Error("Webhook dead-lettered", fields)
+}
+
+// backoff doubles from BaseBackoff per attempt, capped at MaxBackoff.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.opts.BaseBackoff
+	for i := 1; i < attempts && d < q.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.opts.MaxBackoff)
+}
+
+func (q *Queue) untilNext() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	wait := idlePoll
+	now := time.Now()
+	for _, msg := range q.pending {
+		if d := msg.NextAttempt.Sub(now); d < wait {
+			wait = max(d, 0)
+		}
+	}
+	return wait
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) path(sub, id string) string {
+	return filepath.Join(q.dir, sub, id+".json")
+}
+
+// write atomically stores msg under sub and syncs both the file and the
+// directory, so an acknowledged message survives a crash after the rename.
+func (q *Queue) write(sub string, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook message: %w", err)
+	}
+
+	final := q.path(sub, msg.ID)
+	tmp := final + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write webhook message: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write webhook message: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync webhook message: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write webhook message: %w", err)
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		return fmt.Errorf("failed to write webhook message: %w", err)
+	}
+	if err := q.syncDir(sub); err != nil {
+		return fmt.Errorf("failed to sync webhook message: %w", err)
+	}
+	return nil
+}
+
+// remove deletes id from sub and syncs the directory. A missing file is not
+// an error.
+func (q *Queue) remove(sub, id string) error {
+	if err := os.Remove(q.path(sub, id)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return q.syncDir(sub)
+}
+
+// syncDir flushes sub's directory entries so renames and removals in it are
+// durable.
+func (q *Queue) syncDir(sub string) error {
+	d, err := os.Open(filepath.Join(q.dir, sub))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook id: %w", err)
+	}
+	return "whk_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,197 @@
+package webhookqueue_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-gateway/internal/webhookqueue"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for queue")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueue_RetryAndDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+
+	delivered := make(chan string, 10)
+	var failing atomic.Bool
+	failing.Store(true)
+	deliver := func(ctx context.Context, msg *webhookqueue.Message) error {
+		if failing.Load() {
+			return errors.New("payments unavailable")
+		}
+		delivered <- msg.EventID
+		return nil
+	}
+
+	opts := webhookqueue.Options{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	q, err := webhookqueue.Open(dir, deliver, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if _, err := q.Enqueue("evt_1", []byte(`{"id":"evt_1"}`)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	go q.Run(stop)
+
+	waitFor(t, func() bool { return len(q.Dead()) == 1 })
+	close(stop)
+
+	dead := q.Dead()[0]
+	if dead.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", dead.Attempts)
+	}
+	if q.Pending() != 0 {
+		t.Errorf("expected no pending messages, got %d", q.Pending())
+	}
+
+	// Reopening from disk keeps the dead letter, and replay delivers it.
+	failing.Store(false)
+	q, err = webhookqueue.Open(dir, deliver, opts)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if len(q.Dead()) != 1 {
+		t.Fatalf("expected dead letter to survive restart, got %d", len(q.Dead()))
+	}
+
+	if _, err := q.Replay(dead.ID); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if _, err := q.Replay(dead.ID); !errors.Is(err, webhookqueue.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second replay, got %v", err)
+	}
+
+	stop = make(chan struct{})
+	defer close(stop)
+	go q.Run(stop)
+
+	select {
+	case eventID := <-delivered:
+		if eventID != "evt_1" {
+			t.Errorf("expected evt_1, got %s", eventID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replayed message was not delivered")
+	}
+	waitFor(t, func() bool { return q.Pending() == 0 && len(q.Dead()) == 0 })
+}
+
+func TestQueue_PermanentFailure(t *testing.T) {
+	deliver := func(ctx context.Context, msg *webhookqueue.Message) error {
+		return webhookqueue.Permanent(errors.New("rejected with 400"))
+	}
+
+	q, err := webhookqueue.Open(t.TempDir(), deliver, webhookqueue.Options{MaxAttempts: 10})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := q.Enqueue("evt_2", []byte(`{"id":"evt_2"}`)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go q.Run(stop)
+
+	waitFor(t, func() bool { return len(q.Dead()) == 1 })
+	if attempts := q.Dead()[0].Attempts; attempts != 1 {
+		t.Errorf("expected permanent failure after 1 attempt, got %d", attempts)
+	}
+}
+
+func TestQueue_DeadLetterWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	var calls atomic.Int32
+	deliver := func(ctx context.Context, msg *webhookqueue.Message) error {
+		calls.Add(1)
+		return webhookqueue.Permanent(errors.New("rejected with 400"))
+	}
+
+	q, err := webhookqueue.Open(dir, deliver, webhookqueue.Options{BaseBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	msg, err := q.Enqueue("evt_3", []byte(`{"id":"evt_3"}`))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	// Replace the dead-letter directory with a file so writes to it fail.
+	deadDir := filepath.Join(dir, "dead")
+	if err := os.Remove(deadDir); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := os.WriteFile(deadDir, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go q.Run(stop)
+
+	pendingFile := filepath.Join(dir, "pending", msg.ID+".json")
+	waitFor(t, func() bool {
+		data, err := os.ReadFile(pendingFile)
+		return err == nil && strings.Contains(string(data), `"attempts":1`)
+	})
+
+	os.Remove(deadDir)
+	os.Mkdir(deadDir, 0o700)
+	waitFor(t, func() bool { return len(q.Dead()) == 1 && q.Pending() == 0 })
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 delivery attempt, got %d", n)
+	}
+}
+
+func TestQueue_StopCancelsDelivery(t *testing.T) {
+	started := make(chan struct{})
+	deliver := func(ctx context.Context, msg *webhookqueue.Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	q, err := webhookqueue.Open(t.TempDir(), deliver, webhookqueue.Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := q.Enqueue("evt_4", []byte(`{"id":"evt_4"}`)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		q.Run(stop)
+		close(done)
+	}()
+
+	<-started
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after stop")
+	}
+	if q.Pending() != 1 {
+		t.Errorf("expected the interrupted message to stay pending, got %d", q.Pending())
+	}
+}